@@ -0,0 +1,144 @@
+package hypert
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// JSONBodySanitizer sets the values at listed JSON paths of the stored request body to "SANITIZED".
+// Paths are dot-separated, e.g. "credentials.password", and "*" matches any object key or array element, e.g. "users.*.email".
+// The body stays a valid JSON document, and is left as it is, if it's not a JSON or none of the paths matched.
+//
+// Use JSONBodyValidator to skip the sanitized fields during replay validation.
+func JSONBodySanitizer(paths ...string) RequestSanitizer {
+	return jsonBodySanitizer(sanitizeJSONValue, paths)
+}
+
+func jsonBodySanitizer(replace func(any) any, paths []string) RequestSanitizer {
+	parsed := parseJSONPaths(paths)
+	return requestBodySanitizer(func(_ *http.Request, body []byte) []byte {
+		sanitized, _ := replaceJSONPaths(body, parsed, replace)
+		return sanitized
+	})
+}
+
+// requestBodySanitizer reads the whole request body, runs sanitize on it and updates the content length.
+// Encoded (e.g. gzipped) bodies are left as they are.
+func requestBodySanitizer(sanitize func(req *http.Request, body []byte) []byte) RequestSanitizer {
+	return RequestSanitizerFunc(func(req *http.Request) *http.Request {
+		if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+			return req
+		}
+		if spool, streamed := spooledBodyOf(req.Body); streamed {
+			spool.unsanitized = true
+			return req
+		}
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			return req
+		}
+		setRequestBody(req, sanitize(req, body))
+		return req
+	})
+}
+
+func setRequestBody(req *http.Request, body []byte) {
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	if req.Header.Get("Content-Length") != "" {
+		req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+}
+
+// FormBodySanitizer sets listed fields of application/x-www-form-urlencoded request body to "SANITIZED".
+// The body is re-encoded with fields sorted by name, so that it is deterministic. Bodies of other content types are left as they are.
+func FormBodySanitizer(fields ...string) RequestSanitizer {
+	return requestBodySanitizer(func(req *http.Request, body []byte) []byte {
+		mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/x-www-form-urlencoded" {
+			return body
+		}
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return body
+		}
+		for _, field := range fields {
+			if values.Has(field) {
+				values.Set(field, sanitizedValue)
+			}
+		}
+		return []byte(values.Encode())
+	})
+}
+
+// MultipartBodySanitizer sets the content of listed parts of multipart request body to "SANITIZED".
+// The parts are matched by their form name, so both fields and files can be sanitized.
+// The body is re-encoded with NORMALIZED_BOUNDARY boundary, which is also set in Content-Type header,
+// so that it is deterministic. Bodies of other content types are left as they are.
+func MultipartBodySanitizer(parts ...string) RequestSanitizer {
+	sanitized := make(map[string]struct{}, len(parts))
+	for _, p := range parts {
+		sanitized[p] = struct{}{}
+	}
+	return requestBodySanitizer(func(req *http.Request, body []byte) []byte {
+		mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+			return body
+		}
+		reencoded, err := reencodeMultipart(body, params["boundary"], sanitized)
+		if err != nil {
+			return body
+		}
+		params["boundary"] = normalizedBoundary
+		req.Header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+		return reencoded
+	})
+}
+
+func reencodeMultipart(body []byte, boundary string, sanitized map[string]struct{}) ([]byte, error) {
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.SetBoundary(normalizedBoundary); err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := sanitized[part.FormName()]; ok {
+			content = []byte(sanitizedValue)
+		}
+		w, err := writer.CreatePart(part.Header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
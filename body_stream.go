@@ -0,0 +1,270 @@
+package hypert
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// WithBodyStreaming makes TestClient stream the request and response bodies larger than threshold bytes, instead of keeping them in memory.
+// Such bodies are spooled to temporary files and hashed incrementally, and stored in sidecar files next to the recordings,
+// e.g. 0.resp.body.json next to 0.resp.http (see WithSidecarBodies for the extensions), so that the memory usage stays bounded both in record and replay modes.
+// The .http files keep the request or response head with X-Hypert-Body-* headers pointing to the sidecar file.
+//
+// The store has to implement BodyStore interface, which FileStore, MemoryStore and FSStore do.
+//
+// The streamed bodies are not passed to the body sanitizers, so they are stored as they are.
+// They are checked by the leak guard, though, see WithLeakGuard, so that the test fails before the secrets in the body are stored.
+// If a body sanitizer skips the streamed body, and the leak guard is not enabled, the test fails in record mode.
+// RequestData of streamed request has nil BodyBytes, and BodyValidator compares it by BodySize and BodySHA256.
+func WithBodyStreaming(threshold int64) Option {
+	return func(cfg *config) {
+		cfg.bodyStreamThreshold = threshold
+	}
+}
+
+// BodyStore is implemented by the stores, that are able to keep request and response bodies in separate, sidecar files.
+// It is required by WithBodyStreaming option.
+type BodyStore interface {
+	WriteBody(name string, r io.Reader) error
+	OpenBody(name string) (io.ReadCloser, error)
+}
+
+const (
+	bodyFileHeader   = "X-Hypert-Body-File"
+	bodySizeHeader   = "X-Hypert-Body-Size"
+	bodySHA256Header = "X-Hypert-Body-Sha256"
+	bodyFormatHeader = "X-Hypert-Body-Format"
+)
+
+// bodyFileName returns the name of the sidecar file of the request or response stored in name,
+// e.g. 0.resp.body.json for 0.resp.http and ".json" extension.
+func bodyFileName(name, ext string) string {
+	return strings.TrimSuffix(name, ".http") + ".body" + ext
+}
+
+// spooledBody is a body, that was too large to be kept in memory, so it was written to a temporary file.
+type spooledBody struct {
+	path   string
+	size   int64
+	sha256 string
+	// unsanitized is set by the body sanitizers, that skipped the body.
+	unsanitized bool
+}
+
+// spooledReader reads the spooled body. Once the body is streamed, it's passed as spooledReader,
+// so that the body sanitizers skip it, and requestDataFromRequest takes the size and digest without reading it.
+type spooledReader struct {
+	*os.File
+	spool *spooledBody
+	// remove is true for the reader, that owns the temporary file, e.g. the one of the request or response passed to the client.
+	remove bool
+}
+
+func (r *spooledReader) Close() error {
+	err := r.File.Close()
+	if r.remove {
+		os.Remove(r.spool.path)
+	}
+	return err
+}
+
+// open returns a new reader of the spooled body, which doesn't remove the temporary file on Close.
+func (b *spooledBody) open() (*spooledReader, error) {
+	f, err := os.Open(b.path)
+	if err != nil {
+		return nil, fmt.Errorf("open spooled body: %w", err)
+	}
+	return &spooledReader{File: f, spool: b}, nil
+}
+
+// owner returns a new reader of the spooled body, which removes the temporary file on Close.
+func (b *spooledBody) owner() (*spooledReader, error) {
+	r, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	r.remove = true
+	return r, nil
+}
+
+// spooledBodyOf returns the spooled body, if the body was streamed.
+func spooledBodyOf(body io.ReadCloser) (*spooledBody, bool) {
+	r, ok := body.(*spooledReader)
+	if !ok {
+		return nil, false
+	}
+	return r.spool, true
+}
+
+// readBody reads up to threshold bytes of the body into memory. If the body is larger, it's spooled to a temporary file instead.
+// The body is closed.
+func readBody(body io.ReadCloser, threshold int64) ([]byte, *spooledBody, error) {
+	defer body.Close()
+	prefix, err := io.ReadAll(io.LimitReader(body, threshold+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read body: %w", err)
+	}
+	if int64(len(prefix)) <= threshold {
+		return prefix, nil, nil
+	}
+	f, err := os.CreateTemp("", "hypert-body-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create spool file: %w", err)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, hash), io.MultiReader(bytes.NewReader(prefix), body))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, nil, fmt.Errorf("spool body: %w", err)
+	}
+	return nil, &spooledBody{path: f.Name(), size: size, sha256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// spoolRequestBody replaces the request body larger than threshold with spooledReader. Smaller bodies are read into memory.
+// Nothing is done, if threshold is not positive.
+func spoolRequestBody(req *http.Request, threshold int64) error {
+	if threshold <= 0 || req.Body == nil || req.Body == http.NoBody || (req.ContentLength > 0 && req.ContentLength <= threshold) {
+		return nil
+	}
+	if _, ok := spooledBodyOf(req.Body); ok {
+		return nil
+	}
+	body, spool, err := readBody(req.Body, threshold)
+	if err != nil {
+		return fmt.Errorf("request: %w", err)
+	}
+	if spool == nil {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		return nil
+	}
+	r, err := spool.owner()
+	if err != nil {
+		return err
+	}
+	req.Body = r
+	return nil
+}
+
+// closeSpooledBody removes the temporary file of the spooled body, e.g. once it's been validated in replay mode.
+func closeSpooledBody(body io.ReadCloser) {
+	if _, ok := spooledBodyOf(body); ok {
+		body.Close()
+	}
+}
+
+// spoolResponseBody works like spoolRequestBody, but for the response.
+func spoolResponseBody(resp *http.Response, threshold int64) error {
+	if threshold <= 0 || resp.Body == nil || resp.Body == http.NoBody || (resp.ContentLength >= 0 && resp.ContentLength <= threshold) {
+		return nil
+	}
+	body, spool, err := readBody(resp.Body, threshold)
+	if err != nil {
+		return fmt.Errorf("response: %w", err)
+	}
+	if spool == nil {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return nil
+	}
+	r, err := spool.owner()
+	if err != nil {
+		return err
+	}
+	resp.Body = r
+	return nil
+}
+
+// bodyFileRef is the reference to the sidecar file, that is kept in the stored request or response head.
+type bodyFileRef struct {
+	name   string
+	size   int64
+	sha256 string
+	// format is the sidecarFormat* constant describing how the body was transformed before being stored.
+	format string
+}
+
+// setBodyFileRef adds the headers pointing to the sidecar file to the stored request or response head.
+func setBodyFileRef(h http.Header, ref bodyFileRef) {
+	h.Set(bodyFileHeader, ref.name[strings.LastIndexAny(ref.name, `/\`)+1:])
+	h.Set(bodySizeHeader, strconv.FormatInt(ref.size, 10))
+	h.Set(bodySHA256Header, ref.sha256)
+	if ref.format != "" {
+		h.Set(bodyFormatHeader, ref.format)
+	}
+	h.Del("Transfer-Encoding")
+	h.Set("Content-Length", strconv.FormatInt(ref.size, 10))
+}
+
+// takeBodyFileRef reads and removes the headers pointing to the sidecar file.
+// The name is resolved relative to the stored request or response name. ok is false, if the body is stored inline.
+func takeBodyFileRef(h http.Header, name string) (ref bodyFileRef, ok bool, err error) {
+	file := h.Get(bodyFileHeader)
+	if file == "" {
+		return bodyFileRef{}, false, nil
+	}
+	size, err := strconv.ParseInt(h.Get(bodySizeHeader), 10, 64)
+	if err != nil {
+		return bodyFileRef{}, false, fmt.Errorf("invalid %s header: %w", bodySizeHeader, err)
+	}
+	dir := name[:strings.LastIndexAny(name, `/\`)+1]
+	ref = bodyFileRef{name: dir + file, size: size, sha256: h.Get(bodySHA256Header), format: h.Get(bodyFormatHeader)}
+	h.Del(bodyFileHeader)
+	h.Del(bodySizeHeader)
+	h.Del(bodySHA256Header)
+	h.Del(bodyFormatHeader)
+	return ref, true, nil
+}
+
+// writeRequestHead writes the request line and headers of the request, which body is stored in the sidecar file.
+func writeRequestHead(w io.Writer, req *http.Request) error {
+	host := req.Host
+	if host == "" && req.URL != nil {
+		host = req.URL.Host
+	}
+	if _, err := fmt.Fprintf(w, "%s %s HTTP/1.1\r\nHost: %s\r\n", req.Method, req.URL.String(), host); err != nil {
+		return err
+	}
+	if err := req.Header.WriteSubset(w, map[string]bool{"Host": true}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+// writeResponseHead writes the status line and headers of the response, which body is stored in the sidecar file.
+func writeResponseHead(w io.Writer, resp *http.Response) error {
+	text := strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)+" ")
+	if text == "" {
+		text = http.StatusText(resp.StatusCode)
+	}
+	major, minor := resp.ProtoMajor, resp.ProtoMinor
+	if major == 0 {
+		major, minor = 1, 1
+	}
+	if _, err := fmt.Fprintf(w, "HTTP/%d.%d %03d %s\r\n", major, minor, resp.StatusCode, text); err != nil {
+		return err
+	}
+	if err := resp.Header.Write(w); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+// bodyStore returns the store as BodyStore, or an error, if it doesn't implement it.
+func bodyStore(s Store) (BodyStore, error) {
+	bs, ok := s.(BodyStore)
+	if !ok {
+		return nil, fmt.Errorf("store %T doesn't support sidecar body files", s)
+	}
+	return bs, nil
+}
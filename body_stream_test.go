@@ -0,0 +1,123 @@
+package hypert
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyStreaming_RecordAndReplay(t *testing.T) {
+	upload := bytes.Repeat([]byte("upload "), 1000)
+	download := bytes.Repeat([]byte("download "), 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !bytes.Equal(body, upload) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write(download)
+	}))
+	defer srv.Close()
+
+	store := NewMemoryStore()
+	run := func(t *testing.T, mode Mode, body []byte) *mockT {
+		mT := &mockT{}
+		client := TestClient(t, false,
+			WithMode(mode),
+			WithStore(store),
+			WithNamingScheme(&SequentialNamingScheme{dir: "streamed"}),
+			WithBodyStreaming(1024),
+			WithRequestValidator(ComposedRequestValidator(DefaultRequestValidator(), BodyValidator())),
+		)
+		if mode == ModeReplay {
+			client.Transport.(*replayTransport).t = mT
+		}
+		// the reader hides the length, so that it's not known upfront
+		resp, err := client.Post(srv.URL+"/objects", "application/octet-stream", io.MultiReader(bytes.NewReader(body)))
+		if err != nil {
+			t.Fatalf("failed to upload: %v", err)
+		}
+		defer resp.Body.Close()
+		got, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || !bytes.Equal(got, download) {
+			t.Fatalf("unexpected response %d with %d bytes", resp.StatusCode, len(got))
+		}
+		return mT
+	}
+
+	t.Run("record", func(t *testing.T) {
+		run(t, ModeRecord, upload)
+		reqHead := readStoreContent(t, store.OpenRequest, "streamed/0.req.http")
+		digest := sha256.Sum256(upload)
+		for _, expected := range []string{
+			"X-Hypert-Body-File: 0.req.body",
+			"X-Hypert-Body-Size: 7000",
+			"X-Hypert-Body-Sha256: " + hex.EncodeToString(digest[:]),
+		} {
+			if !strings.Contains(reqHead, expected) {
+				t.Errorf("expected request head to contain %q, got:\n%s", expected, reqHead)
+			}
+		}
+		if strings.Contains(reqHead, "upload") {
+			t.Errorf("expected request body to be stored in the sidecar file, got:\n%s", reqHead)
+		}
+		if got := readStoreContent(t, store.OpenBody, "streamed/0.req.body"); got != string(upload) {
+			t.Errorf("expected request body to be stored in the sidecar file, got %d bytes", len(got))
+		}
+		if got := readStoreContent(t, store.OpenBody, "streamed/0.resp.body.txt"); got != string(download) {
+			t.Errorf("expected response body to be stored in the sidecar file, got %d bytes", len(got))
+		}
+	})
+	t.Run("replay", func(t *testing.T) {
+		srv.Close()
+		if mT := run(t, ModeReplay, upload); mT.failed {
+			t.Errorf("expected the request to match the recorded one, got %s", mT.msg)
+		}
+	})
+	t.Run("replay with different body", func(t *testing.T) {
+		changed := bytes.Repeat([]byte("UPLOAD "), 1000)
+		mT := run(t, ModeReplay, changed)
+		if !mT.failed || !strings.Contains(mT.msg, "7000 bytes with SHA-256") {
+			t.Errorf("expected body digest mismatch, got %s", mT.msg)
+		}
+	})
+}
+
+func TestSpoolRequestBody_BelowThreshold(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://example.com", io.MultiReader(strings.NewReader("small")))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	if err := spoolRequestBody(req, 1024); err != nil {
+		t.Fatalf("failed to spool body: %v", err)
+	}
+	data, err := requestDataFromRequest(req)
+	if err != nil {
+		t.Fatalf("failed to get request data: %v", err)
+	}
+	if string(data.BodyBytes) != "small" || data.BodySize != 5 || data.isStreamed() {
+		t.Errorf("expected body to be kept in memory, got %+v", data)
+	}
+}
+
+func readStoreContent(t *testing.T, open func(string) (io.ReadCloser, error), name string) string {
+	t.Helper()
+	f, err := open(name)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", name, err)
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", name, err)
+	}
+	return string(content)
+}
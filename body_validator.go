@@ -0,0 +1,360 @@
+package hypert
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// BodyValidator validates the request body with the validator matching recorded Content-Type header:
+//   - JSONBodyValidator for application/json and */*+json,
+//   - FormBodyValidator for application/x-www-form-urlencoded,
+//   - XMLBodyValidator for application/xml, text/xml and */*+xml,
+//   - MultipartBodyValidator for multipart/*.
+//
+// The bodies of other content types are compared byte by byte.
+// The streamed bodies, see WithBodyStreaming, are compared by size and SHA-256 digest.
+func BodyValidator() RequestValidator {
+	jsonValidator := JSONBodyValidator()
+	formValidator := FormBodyValidator()
+	xmlValidator := XMLBodyValidator()
+	multipartValidator := MultipartBodyValidator()
+	return RequestValidatorFunc(func(t T, recorded RequestData, got RequestData) error {
+		if recorded.isStreamed() || got.isStreamed() {
+			return diffBodyDigests(recorded, got)
+		}
+		mediaType, _, _ := mime.ParseMediaType(recorded.Headers.Get("Content-Type"))
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			return jsonValidator.Validate(t, recorded, got)
+		case mediaType == "application/x-www-form-urlencoded":
+			return formValidator.Validate(t, recorded, got)
+		case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+			return xmlValidator.Validate(t, recorded, got)
+		case strings.HasPrefix(mediaType, "multipart/"):
+			return multipartValidator.Validate(t, recorded, got)
+		default:
+			if !bytes.Equal(recorded.BodyBytes, got.BodyBytes) {
+				return validationError([]Mismatch{{Field: "body", Expected: quoted(string(recorded.BodyBytes)), Got: quoted(string(got.BodyBytes))}})
+			}
+			return nil
+		}
+	})
+}
+
+func diffBodyDigests(recorded, got RequestData) error {
+	if recorded.BodySize == got.BodySize && recorded.BodySHA256 == got.BodySHA256 {
+		return nil
+	}
+	return validationError([]Mismatch{{
+		Field:    "body",
+		Expected: fmt.Sprintf("%d bytes with SHA-256 %s", recorded.BodySize, recorded.BodySHA256),
+		Got:      fmt.Sprintf("%d bytes with SHA-256 %s", got.BodySize, got.BodySHA256),
+	}})
+}
+
+// unexpectedBody is returned, if the body can't be parsed as the recorded one.
+func unexpectedBody(kind string, got []byte) error {
+	return validationError([]Mismatch{{Field: "body", Expected: kind + " document", Got: quoted(string(got))}})
+}
+
+func bothBodiesEmpty(recorded, got RequestData) bool {
+	return len(bytes.TrimSpace(recorded.BodyBytes)) == 0 && len(bytes.TrimSpace(got.BodyBytes)) == 0
+}
+
+type jsonBodyConfig struct {
+	ignored []jsonPath
+}
+
+// JSONBodyOption can be used to customize JSONBodyValidator behaviour.
+type JSONBodyOption func(*jsonBodyConfig)
+
+// WithJSONIgnoredPaths excludes the values at given JSON paths from the comparison, e.g. the generated IDs or timestamps.
+// Paths are dot-separated, e.g. "meta.requestId", and "*" matches any object key or array element, e.g. "items.*.createdAt".
+// The whole subtree of ignored value is skipped.
+func WithJSONIgnoredPaths(paths ...string) JSONBodyOption {
+	return func(cfg *jsonBodyConfig) {
+		cfg.ignored = append(cfg.ignored, parseJSONPaths(paths)...)
+	}
+}
+
+// JSONBodyValidator validates that the request body is the same JSON document as the recorded one.
+// It is not sensitive to the order of object keys, whitespace and number formatting, e.g. 1.0 and 1 are equal.
+// The values that were set to "SANITIZED" in the recorded body, e.g. by JSONBodySanitizer, match any value.
+// All the differences are reported at once, with the paths to the values that differ.
+func JSONBodyValidator(opts ...JSONBodyOption) RequestValidator {
+	cfg := &jsonBodyConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return RequestValidatorFunc(func(t T, recorded RequestData, got RequestData) error {
+		if bothBodiesEmpty(recorded, got) {
+			return nil
+		}
+		recordedBody, err := decodeJSON(recorded.BodyBytes)
+		if err != nil {
+			return fmt.Errorf("recorded body is not a valid JSON: %w", err)
+		}
+		gotBody, err := decodeJSON(got.BodyBytes)
+		if err != nil {
+			return unexpectedBody("JSON", got.BodyBytes)
+		}
+		d := &jsonDiff{ignored: cfg.ignored}
+		d.diff("$", nil, recordedBody, gotBody)
+		return validationError(d.mismatches)
+	})
+}
+
+// FormBodyValidator validates application/x-www-form-urlencoded request body.
+// It is not sensitive to the order of the fields. The values that were set to "SANITIZED" in the recorded body match any value.
+func FormBodyValidator() RequestValidator {
+	cfg := newFieldValidatorConfig(func(name string) string { return name }, nil)
+	return RequestValidatorFunc(func(t T, recorded RequestData, got RequestData) error {
+		recordedForm, err := url.ParseQuery(string(recorded.BodyBytes))
+		if err != nil {
+			return fmt.Errorf("recorded body is not a valid form: %w", err)
+		}
+		gotForm, err := url.ParseQuery(string(got.BodyBytes))
+		if err != nil {
+			return unexpectedBody("form", got.BodyBytes)
+		}
+		return validationError(cfg.diff("body field", recordedForm, gotForm))
+	})
+}
+
+// XMLBodyValidator validates XML request body, e.g. SOAP envelope.
+// The documents are compared after canonicalization: namespace prefixes are resolved to namespace URIs,
+// the order of attributes, namespace declarations, comments and whitespace between elements don't matter.
+// The text and attribute values that were set to "SANITIZED" in the recorded body match any value.
+func XMLBodyValidator() RequestValidator {
+	return RequestValidatorFunc(func(t T, recorded RequestData, got RequestData) error {
+		if bothBodiesEmpty(recorded, got) {
+			return nil
+		}
+		recordedRoot, err := parseXML(recorded.BodyBytes)
+		if err != nil {
+			return fmt.Errorf("recorded body is not a valid XML: %w", err)
+		}
+		gotRoot, err := parseXML(got.BodyBytes)
+		if err != nil {
+			return unexpectedBody("XML", got.BodyBytes)
+		}
+		var mismatches []Mismatch
+		diffXML("", recordedRoot, gotRoot, &mismatches)
+		return validationError(mismatches)
+	})
+}
+
+type xmlNode struct {
+	name     xml.Name
+	attrs    map[xml.Name]string
+	text     string
+	children []*xmlNode
+}
+
+func (n *xmlNode) String() string {
+	if n.name.Space == "" {
+		return n.name.Local
+	}
+	return "{" + n.name.Space + "}" + n.name.Local
+}
+
+func parseXML(b []byte) (*xmlNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	var root *xmlNode
+	var stack []*xmlNode
+	for {
+		token, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: token.Name, attrs: make(map[xml.Name]string)}
+			for _, attr := range token.Attr {
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue // namespaces are already resolved in the names
+				}
+				node.attrs[attr.Name] = attr.Value
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			} else if root == nil {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(token)
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("no root element")
+	}
+	return root, nil
+}
+
+func diffXML(parentPath string, recorded, got *xmlNode, mismatches *[]Mismatch) {
+	add := func(field, expected, got string) {
+		*mismatches = append(*mismatches, Mismatch{Field: "body " + field, Expected: expected, Got: got})
+	}
+	path := parentPath + "/" + recorded.String()
+	if recorded.name != got.name {
+		add(parentPath+"/", "element "+recorded.String(), "element "+got.String())
+		return
+	}
+	recordedText, gotText := strings.TrimSpace(recorded.text), strings.TrimSpace(got.text)
+	if recordedText != sanitizedValue && recordedText != gotText {
+		add(path, quoted(recordedText), quoted(gotText))
+	}
+	for _, name := range sortedXMLNames(recorded.attrs) {
+		gotValue, ok := got.attrs[name]
+		switch {
+		case !ok:
+			add(path+"@"+name.Local, quoted(recorded.attrs[name]), Missing)
+		case recorded.attrs[name] != sanitizedValue && recorded.attrs[name] != gotValue:
+			add(path+"@"+name.Local, quoted(recorded.attrs[name]), quoted(gotValue))
+		}
+	}
+	for _, name := range sortedXMLNames(got.attrs) {
+		if _, ok := recorded.attrs[name]; !ok {
+			add(path+"@"+name.Local, Missing, quoted(got.attrs[name]))
+		}
+	}
+	for i, child := range recorded.children {
+		if i >= len(got.children) {
+			add(path, "element "+child.String(), Missing)
+			continue
+		}
+		diffXML(path, child, got.children[i], mismatches)
+	}
+	for i := len(recorded.children); i < len(got.children); i++ {
+		add(path, Missing, "element "+got.children[i].String())
+	}
+}
+
+func sortedXMLNames(attrs map[xml.Name]string) []xml.Name {
+	names := make([]xml.Name, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i].Space != names[j].Space {
+			return names[i].Space < names[j].Space
+		}
+		return names[i].Local < names[j].Local
+	})
+	return names
+}
+
+// MultipartBodyValidator validates multipart request body. The parts are compared by their form names,
+// so the boundaries don't matter. The contents and file names of the parts are compared, as well as the parts' Content-Type.
+// The parts that were set to "SANITIZED" in the recorded body, e.g. by MultipartBodySanitizer, match any content.
+func MultipartBodyValidator() RequestValidator {
+	return RequestValidatorFunc(func(t T, recorded RequestData, got RequestData) error {
+		if bothBodiesEmpty(recorded, got) {
+			return nil
+		}
+		recordedParts, err := parseMultipart(recorded)
+		if err != nil {
+			return fmt.Errorf("recorded body is not a valid multipart: %w", err)
+		}
+		gotParts, err := parseMultipart(got)
+		if err != nil {
+			return unexpectedBody("multipart", got.BodyBytes)
+		}
+		var mismatches []Mismatch
+		for _, name := range sortedKeys(recordedParts) {
+			field := fmt.Sprintf("body part '%s'", name)
+			gotPartsWithName := gotParts[name]
+			for i, r := range recordedParts[name] {
+				if i >= len(gotPartsWithName) {
+					mismatches = append(mismatches, Mismatch{Field: field, Expected: quoted(string(r.content)), Got: Missing})
+					continue
+				}
+				mismatches = append(mismatches, r.diff(field, gotPartsWithName[i])...)
+			}
+			for _, g := range gotPartsWithName[len(recordedParts[name]):] {
+				mismatches = append(mismatches, Mismatch{Field: field, Expected: Missing, Got: quoted(string(g.content))})
+			}
+		}
+		for _, name := range sortedKeys(gotParts) {
+			if _, ok := recordedParts[name]; !ok {
+				for _, g := range gotParts[name] {
+					mismatches = append(mismatches, Mismatch{Field: fmt.Sprintf("body part '%s'", name), Expected: Missing, Got: quoted(string(g.content))})
+				}
+			}
+		}
+		return validationError(mismatches)
+	})
+}
+
+type multipartPart struct {
+	fileName    string
+	contentType string
+	content     []byte
+}
+
+func (p multipartPart) diff(field string, got multipartPart) []Mismatch {
+	var mismatches []Mismatch
+	if p.fileName != got.fileName {
+		mismatches = append(mismatches, Mismatch{Field: field + " file name", Expected: quoted(p.fileName), Got: quoted(got.fileName)})
+	}
+	if p.contentType != got.contentType {
+		mismatches = append(mismatches, Mismatch{Field: field + " Content-Type", Expected: quoted(p.contentType), Got: quoted(got.contentType)})
+	}
+	if string(p.content) != sanitizedValue && !bytes.Equal(p.content, got.content) {
+		mismatches = append(mismatches, Mismatch{Field: field, Expected: quoted(string(p.content)), Got: quoted(string(got.content))})
+	}
+	return mismatches
+}
+
+// parseMultipart returns the parts grouped by their form names. The parts without form names, e.g. of multipart/mixed,
+// are grouped under their position, e.g. "#0".
+func parseMultipart(data RequestData) (map[string][]multipartPart, error) {
+	_, params, err := mime.ParseMediaType(data.Headers.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("parse Content-Type: %w", err)
+	}
+	if params["boundary"] == "" {
+		return nil, errors.New("no boundary in Content-Type")
+	}
+	reader := multipart.NewReader(bytes.NewReader(data.BodyBytes), params["boundary"])
+	parts := make(map[string][]multipartPart)
+	for i := 0; ; i++ {
+		part, err := reader.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		name := part.FormName()
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		parts[name] = append(parts[name], multipartPart{
+			fileName:    part.FileName(),
+			contentType: part.Header.Get("Content-Type"),
+			content:     content,
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package hypert
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func multipartTestBody(t *testing.T, boundary string, fields map[string]string, fileName, fileContent string) RequestData {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.SetBoundary(boundary); err != nil {
+		t.Fatalf("failed to set boundary: %v", err)
+	}
+	for _, name := range sortedKeys(fields) {
+		if err := w.WriteField(name, fields[name]); err != nil {
+			t.Fatalf("failed to write field: %v", err)
+		}
+	}
+	if fileName != "" {
+		fw, err := w.CreateFormFile("file", fileName)
+		if err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+		if _, err := fw.Write([]byte(fileContent)); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close writer: %v", err)
+	}
+	return RequestData{
+		Headers:   http.Header{"Content-Type": {w.FormDataContentType()}},
+		BodyBytes: buf.Bytes(),
+	}
+}
+
+func bodyTestData(contentType, body string) RequestData {
+	return RequestData{Headers: http.Header{"Content-Type": {contentType}}, BodyBytes: []byte(body)}
+}
+
+func TestBodyValidator(t *testing.T) {
+	const (
+		soapA = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="urn:items">
+			<soap:Body><m:GetItem id="1" lang="en"><m:Token>SANITIZED</m:Token></m:GetItem></soap:Body>
+		</soap:Envelope>`
+		soapB = `<?xml version="1.0"?><e:Envelope xmlns:e="http://schemas.xmlsoap.org/soap/envelope/"><e:Body>` +
+			`<GetItem xmlns="urn:items" lang="en" id="1"><!-- comment --><Token>abc</Token></GetItem></e:Body></e:Envelope>`
+		soapOtherNamespace = `<e:Envelope xmlns:e="http://schemas.xmlsoap.org/soap/envelope/"><e:Body>` +
+			`<GetItem xmlns="urn:other" lang="en" id="1"><Token>abc</Token></GetItem></e:Body></e:Envelope>`
+	)
+	testCases := []struct {
+		name      string
+		recorded  RequestData
+		got       RequestData
+		expectErr bool
+	}{
+		{
+			name:      "json",
+			recorded:  bodyTestData("application/json; charset=utf-8", `{"a": 1, "b": 2}`),
+			got:       bodyTestData("application/json", `{"b":2,"a":1}`),
+			expectErr: false,
+		},
+		{
+			name:      "form_Match",
+			recorded:  bodyTestData("application/x-www-form-urlencoded", "b=2&a=1&secret=SANITIZED&list=x&list=y"),
+			got:       bodyTestData("application/x-www-form-urlencoded", "list=x&list=y&a=1&secret=abc&b=2"),
+			expectErr: false,
+		},
+		{
+			name:      "form_Mismatch",
+			recorded:  bodyTestData("application/x-www-form-urlencoded", "a=1&b=2"),
+			got:       bodyTestData("application/x-www-form-urlencoded", "a=1&b=3"),
+			expectErr: true,
+		},
+		{
+			name:      "xml_Match",
+			recorded:  bodyTestData("text/xml", soapA),
+			got:       bodyTestData("text/xml", soapB),
+			expectErr: false,
+		},
+		{
+			name:      "xml_NamespaceMismatch",
+			recorded:  bodyTestData("application/soap+xml", soapB),
+			got:       bodyTestData("application/soap+xml", soapOtherNamespace),
+			expectErr: true,
+		},
+		{
+			name:      "multipart_Match",
+			recorded:  multipartTestBody(t, "recorded-boundary", map[string]string{"a": "1", "password": "SANITIZED"}, "f.txt", "content"),
+			got:       multipartTestBody(t, "got-boundary", map[string]string{"password": "x", "a": "1"}, "f.txt", "content"),
+			expectErr: false,
+		},
+		{
+			name:      "multipart_FileMismatch",
+			recorded:  multipartTestBody(t, "recorded-boundary", map[string]string{"a": "1"}, "f.txt", "content"),
+			got:       multipartTestBody(t, "got-boundary", map[string]string{"a": "1"}, "f.txt", "other content"),
+			expectErr: true,
+		},
+		{
+			name:      "other_Mismatch",
+			recorded:  bodyTestData("text/plain", "a"),
+			got:       bodyTestData("text/plain", "b"),
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := BodyValidator().Validate(&mockT{}, tc.recorded, tc.got)
+			if tc.expectErr != (err != nil) {
+				t.Errorf("expected error value mismatch. Expected %v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestJSONBodyValidator_Diff(t *testing.T) {
+	recorded := RequestData{BodyBytes: []byte(`{"name": "a", "tags": ["x", "y"], "meta": {"v": 1}}`)}
+	got := RequestData{BodyBytes: []byte(`{"name": "b", "tags": ["x"], "meta": {"v": 1, "extra": true}}`)}
+	err := JSONBodyValidator().Validate(&mockT{}, recorded, got)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	expected := []Mismatch{
+		{Field: "body $.meta.extra", Expected: Missing, Got: "true"},
+		{Field: "body $.name", Expected: `"a"`, Got: `"b"`},
+		{Field: "body $.tags[1]", Expected: `"y"`, Got: Missing},
+	}
+	if !reflect.DeepEqual(validationErr.Mismatches, expected) {
+		t.Errorf("expected mismatches %v, got %v", expected, validationErr.Mismatches)
+	}
+}
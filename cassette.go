@@ -0,0 +1,344 @@
+package hypert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	cassetteExt     = ".cassette.json"
+	bodyEncodingB64 = "base64"
+)
+
+// WithCassette makes TestClient store all the interactions of the test in a single, ordered cassette file,
+// instead of the separate file for each request and response.
+// The cassette is stored in <package name>/testdata/<test name>.cassette.json as JSON.
+// The bodies are stored as text when printable and as base64 otherwise.
+//
+// The names returned by NamingScheme are used to identify interactions within the cassette,
+// so all the naming schemes and replay features work with cassettes the same way as with separate files.
+//
+// To keep the cassette in a different file, use WithStore(NewCassetteStore(path)) instead.
+func WithCassette() Option {
+	return func(cfg *config) {
+		cfg.cassette = true
+	}
+}
+
+type cassetteFile struct {
+	Interactions []*cassetteInteraction `json:"interactions"`
+}
+
+type cassetteInteraction struct {
+	Name     string            `json:"name"`
+	Request  *cassetteRequest  `json:"request,omitempty"`
+	Response *cassetteResponse `json:"response,omitempty"`
+	// Error holds the fields of the error recording, if the call failed, see WithRecordedErrors.
+	Error http.Header `json:"error,omitempty"`
+}
+
+type cassetteRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	cassetteBody
+}
+
+type cassetteResponse struct {
+	Proto      string      `json:"proto"`
+	StatusCode int         `json:"status_code"`
+	Status     string      `json:"status"`
+	Headers    http.Header `json:"headers,omitempty"`
+	cassetteBody
+}
+
+type cassetteBody struct {
+	Body         string `json:"body,omitempty"`
+	BodyEncoding string `json:"body_encoding,omitempty"`
+}
+
+func newCassetteBody(b []byte) cassetteBody {
+	if isPrintable(b) {
+		return cassetteBody{Body: string(b)}
+	}
+	return cassetteBody{Body: base64.StdEncoding.EncodeToString(b), BodyEncoding: bodyEncodingB64}
+}
+
+func (c cassetteBody) bytes() ([]byte, error) {
+	switch c.BodyEncoding {
+	case "":
+		return []byte(c.Body), nil
+	case bodyEncodingB64:
+		return base64.StdEncoding.DecodeString(c.Body)
+	default:
+		return nil, fmt.Errorf("unknown body encoding '%s'", c.BodyEncoding)
+	}
+}
+
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// CassetteStore is a Store, that keeps all the interactions in a single JSON file.
+// The file is loaded on first use and rewritten after each recorded request and response.
+// It should be initialized using NewCassetteStore function.
+type CassetteStore struct {
+	path string
+
+	mu      sync.Mutex
+	loaded  bool
+	file    cassetteFile
+	indexes map[string]int
+}
+
+// NewCassetteStore initializes CassetteStore, that keeps the interactions in the file with given path.
+func NewCassetteStore(path string) *CassetteStore {
+	return &CassetteStore{path: path}
+}
+
+// interactionName returns the name of interaction, that given request or response file belongs to.
+func interactionName(name string) string {
+	name = filepath.Base(name)
+	for _, suffix := range []string{".req.http", ".resp.http"} {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
+
+func (c *CassetteStore) load() error {
+	if c.loaded {
+		return nil
+	}
+	c.indexes = make(map[string]int)
+	content, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		c.loaded = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read cassette %s: %w", c.path, err)
+	}
+	if err := json.Unmarshal(content, &c.file); err != nil {
+		return fmt.Errorf("parse cassette %s: %w", c.path, err)
+	}
+	for i, interaction := range c.file.Interactions {
+		c.indexes[interaction.Name] = i
+	}
+	c.loaded = true
+	return nil
+}
+
+func (c *CassetteStore) save() error {
+	content, err := json.MarshalIndent(c.file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal cassette: %w", err)
+	}
+	return writeFile(c.path, bytes.NewReader(content))
+}
+
+// interaction returns the interaction with given name, creating it if it doesn't exist.
+func (c *CassetteStore) interaction(name string) *cassetteInteraction {
+	if i, ok := c.indexes[name]; ok {
+		return c.file.Interactions[i]
+	}
+	interaction := &cassetteInteraction{Name: name}
+	c.indexes[name] = len(c.file.Interactions)
+	c.file.Interactions = append(c.file.Interactions, interaction)
+	return interaction
+}
+
+func (c *CassetteStore) lookup(name string) (*cassetteInteraction, error) {
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	i, ok := c.indexes[interactionName(name)]
+	if !ok {
+		return nil, fmt.Errorf("interaction %s in cassette %s: %w", interactionName(name), c.path, os.ErrNotExist)
+	}
+	return c.file.Interactions[i], nil
+}
+
+func (c *CassetteStore) WriteRequest(name string, r io.Reader) error {
+	req, err := http.ReadRequest(bufio.NewReader(r))
+	if err != nil {
+		return fmt.Errorf("parse request: %w", err)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return fmt.Errorf("read request body: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return err
+	}
+	c.interaction(interactionName(name)).Request = &cassetteRequest{
+		Method:       req.Method,
+		URL:          req.URL.String(),
+		Headers:      req.Header,
+		cassetteBody: newCassetteBody(body),
+	}
+	return c.save()
+}
+
+func (c *CassetteStore) WriteResponse(name string, r io.Reader) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return err
+	}
+	interaction := c.interaction(interactionName(name))
+	if isErrorRecording(content) {
+		fields, err := readErrorRecording(bytes.NewReader(content))
+		if err != nil {
+			return fmt.Errorf("parse error recording: %w", err)
+		}
+		interaction.Response, interaction.Error = nil, fields
+		return c.save()
+	}
+	// the request is needed to correctly parse responses without body, e.g. to HEAD requests
+	var req *http.Request
+	if interaction.Request != nil {
+		req = &http.Request{Method: interaction.Request.Method}
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(content)), req)
+	if err != nil {
+		return fmt.Errorf("parse response: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response body: %w", err)
+	}
+	interaction.Error = nil
+	interaction.Response = &cassetteResponse{
+		Proto:        resp.Proto,
+		StatusCode:   resp.StatusCode,
+		Status:       resp.Status,
+		Headers:      resp.Header,
+		cassetteBody: newCassetteBody(body),
+	}
+	return c.save()
+}
+
+func (c *CassetteStore) OpenRequest(name string) (io.ReadCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	interaction, err := c.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if interaction.Request == nil {
+		return nil, fmt.Errorf("request of interaction %s in cassette %s: %w", interaction.Name, c.path, os.ErrNotExist)
+	}
+	body, err := interaction.Request.bytes()
+	if err != nil {
+		return nil, fmt.Errorf("decode request body of interaction %s: %w", interaction.Name, err)
+	}
+	u, err := url.Parse(interaction.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse request url of interaction %s: %w", interaction.Name, err)
+	}
+	req := &http.Request{
+		Method:        interaction.Request.Method,
+		URL:           u,
+		Header:        interaction.Request.Headers,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	var buf bytes.Buffer
+	if err := req.WriteProxy(&buf); err != nil {
+		return nil, fmt.Errorf("write request of interaction %s: %w", interaction.Name, err)
+	}
+	return io.NopCloser(&buf), nil
+}
+
+func (c *CassetteStore) OpenResponse(name string) (io.ReadCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	interaction, err := c.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if interaction.Error != nil {
+		var buf bytes.Buffer
+		if err := writeErrorRecording(&buf, interaction.Error); err != nil {
+			return nil, fmt.Errorf("write error recording of interaction %s: %w", interaction.Name, err)
+		}
+		return io.NopCloser(&buf), nil
+	}
+	if interaction.Response == nil {
+		return nil, fmt.Errorf("response of interaction %s in cassette %s: %w", interaction.Name, c.path, os.ErrNotExist)
+	}
+	body, err := interaction.Response.bytes()
+	if err != nil {
+		return nil, fmt.Errorf("decode response body of interaction %s: %w", interaction.Name, err)
+	}
+	resp := &http.Response{
+		Status:        interaction.Response.Status,
+		StatusCode:    interaction.Response.StatusCode,
+		Header:        interaction.Response.Headers,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+	var ok bool
+	resp.ProtoMajor, resp.ProtoMinor, ok = http.ParseHTTPVersion(interaction.Response.Proto)
+	if !ok {
+		resp.ProtoMajor, resp.ProtoMinor = 1, 1
+	}
+	var buf bytes.Buffer
+	if err := resp.Write(&buf); err != nil {
+		return nil, fmt.Errorf("write response of interaction %s: %w", interaction.Name, err)
+	}
+	return io.NopCloser(&buf), nil
+}
+
+// List returns the recorded interactions in the order they are kept in the cassette.
+func (c *CassetteStore) List() ([]Recording, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	var recordings []Recording
+	for _, interaction := range c.file.Interactions {
+		if interaction.Request == nil {
+			continue
+		}
+		recordings = append(recordings, Recording{
+			Request:  interaction.Name + ".req.http",
+			Response: interaction.Name + ".resp.http",
+		})
+	}
+	return recordings, nil
+}
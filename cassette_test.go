@@ -0,0 +1,112 @@
+package hypert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassetteStore_RecordAndReplay(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "TestSomething"+cassetteExt)
+	scheme := &SequentialNamingScheme{dir: "TestSomething"}
+	binaryBody := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff}
+
+	mockRT := &mockRoundTripper{
+		resp: &http.Response{ //nolint:bodyclose // Response body is closed after the round trip
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"image/png"}},
+			Body:       io.NopCloser(bytes.NewReader(binaryBody)),
+		},
+	}
+	record := &recordTransport{
+		httpTransport: mockRT,
+		namingScheme:  scheme,
+		sanitizer:     HeadersSanitizer("Authorization"),
+		store:         NewCassetteStore(cassettePath),
+	}
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/upload?x=1", strings.NewReader(`{"name":"test"}`))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "secret")
+	resp, err := record.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+	resp.Body.Close()
+
+	content, err := os.ReadFile(cassettePath)
+	if err != nil {
+		t.Fatalf("failed to read cassette: %v", err)
+	}
+	var cassette cassetteFile
+	if err := json.Unmarshal(content, &cassette); err != nil {
+		t.Fatalf("failed to parse cassette: %v", err)
+	}
+	if len(cassette.Interactions) != 1 {
+		t.Fatalf("expected 1 interaction, got %d", len(cassette.Interactions))
+	}
+	interaction := cassette.Interactions[0]
+	if interaction.Request.Body != `{"name":"test"}` || interaction.Request.BodyEncoding != "" {
+		t.Errorf("expected printable request body to be stored as text, got %q (%s)", interaction.Request.Body, interaction.Request.BodyEncoding)
+	}
+	if interaction.Request.Headers.Get("Authorization") != "SANITIZED" {
+		t.Errorf("expected stored request to be sanitized, got %q", interaction.Request.Headers.Get("Authorization"))
+	}
+	if interaction.Response.BodyEncoding != bodyEncodingB64 {
+		t.Errorf("expected binary response body to be stored as base64, got %q", interaction.Response.BodyEncoding)
+	}
+
+	var validated bool
+	replay := &replayTransport{
+		t:         t,
+		scheme:    &SequentialNamingScheme{dir: "TestSomething"},
+		sanitizer: HeadersSanitizer("Authorization"),
+		validator: RequestValidatorFunc(func(_ T, recorded RequestData, got RequestData) error {
+			validated = true
+			if string(recorded.BodyBytes) != string(got.BodyBytes) {
+				t.Errorf("expected recorded body %q, got %q", got.BodyBytes, recorded.BodyBytes)
+			}
+			if recorded.URL.String() != got.URL.String() {
+				t.Errorf("expected recorded url %q, got %q", got.URL, recorded.URL)
+			}
+			return nil
+		}),
+		store: NewCassetteStore(cassettePath),
+	}
+	req, err = http.NewRequest(http.MethodPost, "https://example.com/upload?x=1", strings.NewReader(`{"name":"test"}`))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	resp, err = replay.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("failed to replay: %v", err)
+	}
+	defer resp.Body.Close()
+	if !validated {
+		t.Error("expected validator to be called")
+	}
+	gotBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if !bytes.Equal(gotBody, binaryBody) {
+		t.Errorf("expected replayed body %v, got %v", binaryBody, gotBody)
+	}
+	if resp.Header.Get("Content-Type") != "image/png" {
+		t.Errorf("expected Content-Type header to be replayed, got %q", resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestCassetteStore_MissingInteraction(t *testing.T) {
+	store := NewCassetteStore(filepath.Join(t.TempDir(), "missing"+cassetteExt))
+	if _, err := store.OpenRequest("0.req.http"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
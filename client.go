@@ -1,17 +1,36 @@
 package hypert
 
 import (
+	"fmt"
+	"io/fs"
 	"net/http"
+	"path"
 )
 
 type config struct {
-	isRecordMode     bool
+	mode             Mode
 	transformMode    TransformRespMode
 	transform        ResponseTransform
 	namingScheme     NamingScheme
 	requestSanitizer RequestSanitizer
 	requestValidator RequestValidator
 	parentHTTPClient *http.Client
+
+	responseSanitizer ResponseSanitizer
+	leakGuard         *leakGuard
+
+	bodyStreamThreshold int64
+	sidecarBodies       bool
+	decodeBodies        bool
+	contentCodings      contentCodings
+	recordErrors        bool
+
+	unusedRecordingsCheck UnusedRecordingsCheck
+	matchingReplay        bool
+	cassette              bool
+	store                 Store
+	replayFS              fs.FS
+	replayFSRoot          string
 }
 
 // Option can be used to customize TestClient behaviour. See With* functions to find customization options
@@ -42,6 +61,15 @@ func WithRequestSanitizer(sanitizer RequestSanitizer) Option {
 	}
 }
 
+// WithResponseSanitizer configures ResponseSanitizer applied to the stored responses.
+// The response returned to the client in record mode is not sanitized.
+// DefaultResponseSanitizer is used by default.
+func WithResponseSanitizer(sanitizer ResponseSanitizer) Option {
+	return func(cfg *config) {
+		cfg.responseSanitizer = sanitizer
+	}
+}
+
 // WithRequestValidator allows user to set the request validator.
 func WithRequestValidator(v RequestValidator) Option {
 	return func(cfg *config) {
@@ -49,6 +77,45 @@ func WithRequestValidator(v RequestValidator) Option {
 	}
 }
 
+// Mode defines how TestClient treats the HTTP requests.
+type Mode int
+
+const (
+	// ModeReplay replays previously recorded requests, without making actual HTTP calls.
+	ModeReplay Mode = iota
+	// ModeRecord makes actual HTTP calls and stores the requests and responses.
+	ModeRecord
+	// ModeHybrid replays the requests that have matching recordings and makes actual HTTP calls for the ones,
+	// that haven't been recorded yet. The new requests are stored, so that they can be replayed afterwards.
+	// It's useful, when a new call is added to an existing test, because other requests don't need to be recorded again.
+	ModeHybrid
+	// ModePassthrough makes actual HTTP calls without storing or replaying anything.
+	ModePassthrough
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeReplay:
+		return "replay"
+	case ModeRecord:
+		return "record"
+	case ModeHybrid:
+		return "hybrid"
+	case ModePassthrough:
+		return "passthrough"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+}
+
+// WithMode sets the mode of the TestClient. It takes precedence over the recordModeOn argument of TestClient.
+// Both of them can be overridden with HYPERT_MODE environment variable or -hypert.mode test flag, see TestClient.
+func WithMode(mode Mode) Option {
+	return func(cfg *config) {
+		cfg.mode = mode
+	}
+}
+
 type TransformRespMode int
 
 const (
@@ -86,59 +153,159 @@ func WithResponseTransform(mode TransformRespMode, transform ResponseTransform)
 // In most scenarios, you'd set recordModeOn to true during the development, when you have set up the authentication to the HTTP API you're using.
 // This will result in the requests and response pairs being stored in <package name>/testdata/<test name>/<sequential number>.(req|resp).http
 // Before the requests are stored, they are sanitized using DefaultRequestSanitizer. It can be adjusted using WithRequestSanitizer option.
-// Ensure that sanitization works as expected, otherwise sensitive details might be committed
+// Responses are sanitized with DefaultResponseSanitizer before being stored, which can be adjusted using WithResponseSanitizer option.
+// Ensure that sanitization works as expected, otherwise sensitive details might be committed. WithLeakGuard option can help with that.
 //
 // recordModeOn should be false when given test is not actively worked on, so in most cases the committed value should be false.
 // This mode will result in the requests and response pairs previously stored being replayed, mimicking interactions with actual HTTP APIs,
 // but skipping making actual calls.
+//
+// If only some of the requests should be recorded, use WithMode(ModeHybrid) option.
+//
+// The mode set in the code can be overridden without editing it, with -hypert.mode test flag or HYPERT_MODE environment variable,
+// set to one of record, replay, hybrid or passthrough. The flag takes precedence over the environment variable.
+// The override can be limited to the tests with names matching the regular expression passed with -hypert.run flag or HYPERT_RUN environment variable,
+// e.g. HYPERT_MODE=record HYPERT_RUN='^TestMyAPI$' go test ./...
+// The flags are defined only in the packages importing hypert, so they can be used when testing a single package,
+// e.g. go test ./myapi -hypert.mode=record -hypert.run='^TestMyAPI$'
 func TestClient(t T, recordModeOn bool, opts ...Option) *http.Client {
 	t.Helper()
 	cfg := configWithDefaults(t, recordModeOn, opts)
 
+	used := newUsedRecordings()
 	var transport http.RoundTripper
-	if cfg.isRecordMode {
+	switch cfg.mode {
+	case ModeRecord:
 		t.Log("hypert: record request mode - requests will be stored")
-		transport = &recordTransport{
-			httpTransport: cfg.parentHTTPClient.Transport,
-			namingScheme:  cfg.namingScheme,
-			sanitizer:     cfg.requestSanitizer,
-			transformMode: cfg.transformMode,
-			transform:     cfg.transform,
+		transport = newRecordTransport(t, cfg)
+	case ModeHybrid:
+		t.Log("hypert: hybrid request mode - recorded requests will be replayed, the missing ones will be stored")
+		transport = &hybridTransport{
+			t:         t,
+			scheme:    cfg.namingScheme,
+			sanitizer: cfg.requestSanitizer,
+			replay:    newReplayTransport(t, cfg, used),
+			record:    newRecordTransport(t, cfg),
 		}
-	} else {
+		registerUnusedRecordingsCheck(t, cfg.unusedRecordingsCheck, cfg.store, used)
+	case ModeReplay:
 		t.Log("hypert: replay request mode - requests will be read from previously stored files.")
-		transport = &replayTransport{
-			t:             t,
-			scheme:        cfg.namingScheme,
-			validator:     cfg.requestValidator,
-			sanitizer:     cfg.requestSanitizer,
-			transform:     cfg.transform,
-			transformMode: cfg.transformMode,
+		if cfg.matchingReplay {
+			transport = newMatchingReplayTransport(t, cfg, used)
+		} else {
+			transport = newReplayTransport(t, cfg, used)
 		}
+		registerUnusedRecordingsCheck(t, cfg.unusedRecordingsCheck, cfg.store, used)
+	case ModePassthrough:
+		t.Log("hypert: passthrough request mode - requests will be neither stored nor replayed")
+		transport = cfg.parentHTTPClient.Transport
+	default:
+		t.Fatalf("hypert: unknown mode %s", cfg.mode)
 	}
 	cfg.parentHTTPClient.Transport = transport
 	return cfg.parentHTTPClient
 }
 
+func newRecordTransport(t T, cfg *config) *recordTransport {
+	return &recordTransport{
+		t:               t,
+		httpTransport:   cfg.parentHTTPClient.Transport,
+		namingScheme:    cfg.namingScheme,
+		sanitizer:       cfg.requestSanitizer,
+		respSanitizer:   cfg.responseSanitizer,
+		transformMode:   cfg.transformMode,
+		transform:       cfg.transform,
+		store:           cfg.store,
+		leakGuard:       cfg.leakGuard,
+		streamThreshold: cfg.bodyStreamThreshold,
+		sidecarBodies:   cfg.sidecarBodies,
+		decoder:         decoderOf(cfg),
+		recordErrors:    cfg.recordErrors,
+	}
+}
+
+// decoderOf returns the content codings used to decode the stored bodies, or nil if they should be stored as they are.
+func decoderOf(cfg *config) contentCodings {
+	if !cfg.decodeBodies {
+		return nil
+	}
+	return cfg.contentCodings
+}
+
+func newReplayTransport(t T, cfg *config, used *usedRecordings) *replayTransport {
+	return &replayTransport{
+		t:               t,
+		scheme:          cfg.namingScheme,
+		validator:       cfg.requestValidator,
+		sanitizer:       cfg.requestSanitizer,
+		respSanitizer:   cfg.responseSanitizer,
+		transform:       cfg.transform,
+		transformMode:   cfg.transformMode,
+		used:            used,
+		store:           cfg.store,
+		streamThreshold: cfg.bodyStreamThreshold,
+		codings:         cfg.contentCodings,
+	}
+}
+
+func newMatchingReplayTransport(t T, cfg *config, used *usedRecordings) *matchingReplayTransport {
+	return &matchingReplayTransport{
+		replay: newReplayTransport(t, cfg, used),
+	}
+}
+
 func configWithDefaults(t T, recordModeOn bool, opts []Option) *config {
 	cfg := &config{
-		isRecordMode: recordModeOn,
+		mode: ModeReplay,
+	}
+	if recordModeOn {
+		cfg.mode = ModeRecord
 	}
 	for _, opt := range opts {
 		opt(cfg)
 	}
+	cfg.mode = resolveMode(t, cfg.mode, modeOverrideFromFlagsAndEnv())
+	if cfg.replayFS != nil {
+		configureReplayFS(t, cfg)
+	}
+	if cfg.cassette && cfg.store == nil {
+		cassettePath := DefaultTestDataDir(t) + cassetteExt
+		t.Logf("hypert: using cassette %s", cassettePath)
+		cfg.store = NewCassetteStore(cassettePath)
+	}
 	if cfg.namingScheme == nil {
 		requestsDir := DefaultTestDataDir(t)
-		t.Logf("hypert: using sequential naming scheme in %s directory", requestsDir)
-		scheme, err := NewSequentialNamingScheme(requestsDir)
-		if err != nil {
-			t.Fatalf("failed to create naming scheme: %s", err.Error())
+		if cfg.cassette {
+			// with cassette the names only identify interactions, so the directory is not created
+			cfg.namingScheme = &SequentialNamingScheme{dir: requestsDir}
+		} else {
+			t.Logf("hypert: using sequential naming scheme in %s directory", requestsDir)
+			scheme, err := NewSequentialNamingScheme(requestsDir)
+			if err != nil {
+				t.Fatalf("failed to create naming scheme: %s", err.Error())
+			}
+			cfg.namingScheme = scheme
+		}
+	}
+	if cfg.store == nil {
+		var dir string
+		if dirScheme, ok := cfg.namingScheme.(DirNamingScheme); ok {
+			dir = dirScheme.Dir()
 		}
-		cfg.namingScheme = scheme
+		cfg.store = NewFileStore(dir)
+	}
+	if _, err := bodyStore(cfg.store); (cfg.bodyStreamThreshold > 0 || cfg.sidecarBodies) && err != nil {
+		t.Fatalf("hypert: bodies can't be stored in sidecar files: %v", err)
+	}
+	if cfg.contentCodings == nil {
+		cfg.contentCodings = defaultContentCodings()
 	}
 	if cfg.requestSanitizer == nil {
 		cfg.requestSanitizer = DefaultRequestSanitizer()
 	}
+	if cfg.responseSanitizer == nil {
+		cfg.responseSanitizer = DefaultResponseSanitizer()
+	}
 	if cfg.parentHTTPClient == nil {
 		cfg.parentHTTPClient = &http.Client{}
 	}
@@ -148,6 +315,24 @@ func configWithDefaults(t T, recordModeOn bool, opts []Option) *config {
 	return cfg
 }
 
+func configureReplayFS(t T, cfg *config) {
+	t.Helper()
+	if cfg.mode != ModeReplay {
+		t.Fatalf("hypert: replay file system can be used only in replay mode, got %s mode", cfg.mode)
+	}
+	if cfg.cassette {
+		t.Fatalf("hypert: replay file system can't be used with cassette")
+	}
+	dir := path.Join(cfg.replayFSRoot, t.Name())
+	t.Logf("hypert: replaying from %s directory of the file system", dir)
+	if cfg.namingScheme == nil {
+		cfg.namingScheme = &SequentialNamingScheme{dir: dir}
+	}
+	if cfg.store == nil {
+		cfg.store = NewFSStore(cfg.replayFS, dir)
+	}
+}
+
 // T is a subset of testing.T interface that is used by hypert's functions.
 // custom T's implementation can be used to e.g. make logs silent, stop failing on errors and others.
 type T interface {
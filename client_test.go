@@ -20,8 +20,8 @@ func (n noopRequestValidator) Validate(_ T, _, _ RequestData) error {
 func Test_configWithDefaults(t *testing.T) {
 	t.Run("should return default config", func(t *testing.T) {
 		cfg := configWithDefaults(t, false, nil)
-		if cfg.isRecordMode {
-			t.Error("expected isRecordMode to be false")
+		if cfg.mode != ModeReplay {
+			t.Errorf("expected mode to be %s, got %s", ModeReplay, cfg.mode)
 		}
 		if cfg.namingScheme == nil {
 			t.Error("expected namingScheme to be set")
@@ -47,9 +47,10 @@ func Test_configWithDefaults(t *testing.T) {
 			WithRequestSanitizer(sanitizer),
 			WithNamingScheme(namingScheme),
 			WithParentHTTPClient(parentHTTPClient),
+			WithMode(ModeHybrid),
 		})
-		if cfg.isRecordMode {
-			t.Error("expected isRecordMode to be false")
+		if cfg.mode != ModeHybrid {
+			t.Errorf("expected mode to be %s, got %s", ModeHybrid, cfg.mode)
 		}
 		if cfg.namingScheme != namingScheme {
 			t.Error("expected namingScheme to be set")
@@ -85,4 +86,13 @@ func Test_TestClient(t *testing.T) {
 			t.Error("expected transport to be replayTransport")
 		}
 	})
+	t.Run("when hybrid mode is on, it should use hybrid transport", func(t *testing.T) {
+		c := TestClient(t, false, WithMode(ModeHybrid))
+		if c.Transport == nil {
+			t.Fatal("expected transport to be set")
+		}
+		if _, ok := c.Transport.(*hybridTransport); !ok {
+			t.Error("expected transport to be hybridTransport")
+		}
+	})
 }
@@ -0,0 +1,112 @@
+// Command hypert provides tools for working with the recordings of hypert's TestClient.
+//
+// Usage:
+//
+//	hypert har export [-o file] <recordings directory>
+//	hypert har import [-host host] [-path regexp] <HAR file> <recordings directory>
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/areknoster/hypert"
+)
+
+const usage = `usage:
+	hypert har export [-o file] <recordings directory>
+		converts the recordings from the directory, e.g. testdata/TestMyAPI, to HAR 1.2 file
+	hypert har import [-host host] [-path regexp] <HAR file> <recordings directory>
+		converts HAR file entries to sanitized recordings, named sequentially in the directory`
+
+var errUsage = errors.New(usage)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) < 2 || args[0] != "har" {
+		return errUsage
+	}
+	switch args[1] {
+	case "export":
+		return harExport(args[2:], stdout)
+	case "import":
+		return harImport(args[2:], stdout)
+	default:
+		return errUsage
+	}
+}
+
+func harExport(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("har export", flag.ContinueOnError)
+	out := fs.String("o", "", "output file, standard output by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	store := hypert.NewFileStore(fs.Arg(0))
+	if *out == "" {
+		return hypert.ExportHAR(store, stdout)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("create output file: %w", err)
+	}
+	if err := hypert.ExportHAR(store, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func harImport(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("har import", flag.ContinueOnError)
+	host := fs.String("host", "", "import only the requests to given host")
+	path := fs.String("path", "", "import only the requests with paths matching the regular expression")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errUsage
+	}
+
+	var opts []hypert.HARImportOption
+	if *host != "" {
+		opts = append(opts, hypert.WithHARHosts(*host))
+	}
+	if *path != "" {
+		re, err := regexp.Compile(*path)
+		if err != nil {
+			return fmt.Errorf("compile path regular expression: %w", err)
+		}
+		opts = append(opts, hypert.WithHARPath(re))
+	}
+	scheme, err := hypert.NewSequentialNamingScheme(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("create naming scheme: %w", err)
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("open HAR file: %w", err)
+	}
+	defer f.Close()
+
+	imported, err := hypert.ImportHAR(f, scheme, opts...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "imported %d entries to %s\n", imported, fs.Arg(1))
+	return nil
+}
@@ -0,0 +1,194 @@
+package hypert
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ContentCoding decodes and encodes the bodies with given Content-Encoding, e.g. gzip.
+type ContentCoding interface {
+	NewReader(r io.Reader) (io.ReadCloser, error)
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// ContentCodingFuncs is a convenience type that implements ContentCoding interface, e.g. for the brotli or zstd packages:
+//
+//	hypert.ContentCodingFuncs{
+//		Reader: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(brotli.NewReader(r)), nil },
+//		Writer: func(w io.Writer) (io.WriteCloser, error) { return brotli.NewWriter(w), nil },
+//	}
+type ContentCodingFuncs struct {
+	Reader func(r io.Reader) (io.ReadCloser, error)
+	Writer func(w io.Writer) (io.WriteCloser, error)
+}
+
+func (c ContentCodingFuncs) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return c.Reader(r)
+}
+
+func (c ContentCodingFuncs) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return c.Writer(w)
+}
+
+// GzipCoding returns ContentCoding for gzip Content-Encoding.
+func GzipCoding() ContentCoding {
+	return ContentCodingFuncs{
+		Reader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+		Writer: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+	}
+}
+
+// DeflateCoding returns ContentCoding for deflate Content-Encoding, which is zlib format according to RFC 9110.
+func DeflateCoding() ContentCoding {
+	return ContentCodingFuncs{
+		Reader: func(r io.Reader) (io.ReadCloser, error) { return zlib.NewReader(r) },
+		Writer: func(w io.Writer) (io.WriteCloser, error) { return zlib.NewWriter(w), nil },
+	}
+}
+
+// WithDecodedBodies makes TestClient store the encoded response bodies, e.g. gzipped ones, decoded, so that the recordings are human-readable.
+// It's useful, when the client sets Accept-Encoding header itself, so that http.Transport doesn't decode the response.
+// Decoded bodies are also visible to the response sanitizers and the leak guard, which skip the encoded ones.
+//
+// The original Content-Encoding is kept in X-Hypert-Content-Encoding header of the stored response,
+// and the body is encoded again in replay mode, if the request's Accept-Encoding allows it, so that the client gets the encoding it negotiated.
+// The bytes of the replayed body might differ from the recorded ones, but they decode to the same content.
+//
+// gzip and deflate are supported out of the box. Other encodings, e.g. br or zstd, can be registered with WithContentCoding.
+// The responses with other encodings, multiple encodings or streamed bodies are stored as they are.
+// The test fails in record mode, if the body can't be decoded with the registered coding.
+func WithDecodedBodies() Option {
+	return func(cfg *config) {
+		cfg.decodeBodies = true
+	}
+}
+
+// WithContentCoding registers ContentCoding for given Content-Encoding, e.g. "br" or "zstd", so that it's used by WithDecodedBodies.
+// The coding is needed in replay mode as well, to encode the decoded bodies again.
+func WithContentCoding(encoding string, coding ContentCoding) Option {
+	return func(cfg *config) {
+		if cfg.contentCodings == nil {
+			cfg.contentCodings = defaultContentCodings()
+		}
+		cfg.contentCodings[strings.ToLower(encoding)] = coding
+	}
+}
+
+// contentCodings maps lowercase Content-Encoding values to their codings.
+type contentCodings map[string]ContentCoding
+
+func defaultContentCodings() contentCodings {
+	return contentCodings{
+		"gzip":    GzipCoding(),
+		"x-gzip":  GzipCoding(),
+		"deflate": DeflateCoding(),
+	}
+}
+
+// decodedEncodingHeader keeps the original Content-Encoding of the response, which body is stored decoded.
+const decodedEncodingHeader = "X-Hypert-Content-Encoding"
+
+// decodeResponse decodes the body of the response, if it has a single, registered Content-Encoding.
+// The body is read into memory.
+func (c contentCodings) decodeResponse(resp *http.Response) (*http.Response, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	coding, ok := c[encoding]
+	if !ok || resp.Body == nil || resp.Body == http.NoBody {
+		return resp, nil
+	}
+	if _, streamed := spooledBodyOf(resp.Body); streamed {
+		return resp, nil
+	}
+	encoded, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read %s body: %w", encoding, err)
+	}
+	if len(encoded) == 0 {
+		resp.Body = http.NoBody
+		return resp, nil
+	}
+	r, err := coding.NewReader(bytes.NewReader(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("decode %s body: %w", encoding, err)
+	}
+	defer r.Close()
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s body: %w", encoding, err)
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Set(decodedEncodingHeader, encoding)
+	setResponseBody(resp, decoded)
+	return resp, nil
+}
+
+// encodeResponse reverses decodeResponse for the replayed response with in-memory body.
+// The body is left decoded, if the request's Accept-Encoding doesn't allow the encoding,
+// e.g. when it's added and handled by http.Transport, which decodes the body transparently.
+func (c contentCodings) encodeResponse(resp *http.Response, body []byte, acceptEncoding string) error {
+	encoding := resp.Header.Get(decodedEncodingHeader)
+	if encoding == "" {
+		return nil
+	}
+	resp.Header.Del(decodedEncodingHeader)
+	if !acceptsEncoding(acceptEncoding, encoding) {
+		return nil
+	}
+	coding, ok := c[encoding]
+	if !ok {
+		return fmt.Errorf("no content coding registered for %s encoding, lookup WithContentCoding option", encoding)
+	}
+	var buf bytes.Buffer
+	w, err := coding.NewWriter(&buf)
+	if err != nil {
+		return fmt.Errorf("encode %s body: %w", encoding, err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("encode %s body: %w", encoding, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("encode %s body: %w", encoding, err)
+	}
+	resp.Header.Set("Content-Encoding", encoding)
+	setResponseBody(resp, buf.Bytes())
+	return nil
+}
+
+// acceptsEncoding tells, whether Accept-Encoding header value allows the encoding, e.g. "gzip, br;q=0.5" allows gzip and br.
+func acceptsEncoding(acceptEncoding, encoding string) bool {
+	accepted := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != encoding && name != "*" {
+			continue
+		}
+		q := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(key) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+		if name == encoding {
+			// the exact value takes precedence over the wildcard
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
+
+// setResponseBody replaces the body of the response, keeping the length consistent.
+func setResponseBody(resp *http.Response, body []byte) {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
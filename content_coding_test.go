@@ -0,0 +1,233 @@
+package hypert
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDecodedBodies_RecordAndReplay(t *testing.T) {
+	const body = `{"message":"hello"}`
+	testCases := []struct {
+		name     string
+		encoding string
+		opts     []Option
+	}{
+		{name: "gzip", encoding: "gzip"},
+		{name: "deflate", encoding: "deflate"},
+		{name: "registered coding", encoding: "x-custom", opts: []Option{WithContentCoding("X-Custom", DeflateCoding())}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			codings := defaultContentCodings()
+			codings["x-custom"] = DeflateCoding()
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Accept-Encoding") != tc.encoding {
+					_, _ = io.WriteString(w, body)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Encoding", tc.encoding)
+				_, _ = w.Write(encodeTestBody(t, codings[tc.encoding], body))
+			}))
+			defer srv.Close()
+
+			store := NewMemoryStore()
+			get := func(t *testing.T, mode Mode) {
+				opts := append([]Option{
+					WithMode(mode),
+					WithStore(store),
+					WithNamingScheme(&SequentialNamingScheme{dir: "decoded"}),
+					WithDecodedBodies(),
+				}, tc.opts...)
+				client := TestClient(t, false, opts...)
+				req, err := http.NewRequest(http.MethodGet, srv.URL+"/messages", http.NoBody)
+				if err != nil {
+					t.Fatalf("failed to create request: %v", err)
+				}
+				req.Header.Set("Accept-Encoding", tc.encoding)
+				resp, err := client.Do(req)
+				if err != nil {
+					t.Fatalf("failed to make request: %v", err)
+				}
+				defer resp.Body.Close()
+				if got := resp.Header.Get("Content-Encoding"); got != tc.encoding {
+					t.Fatalf("expected %s Content-Encoding, got '%s'", tc.encoding, got)
+				}
+				r, err := codings[tc.encoding].NewReader(resp.Body)
+				if err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				decoded, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if string(decoded) != body {
+					t.Errorf("expected decoded body %s, got %s", body, decoded)
+				}
+			}
+
+			get(t, ModeRecord)
+			stored := readStoreContent(t, store.OpenResponse, "decoded/0.resp.http")
+			for _, expected := range []string{body, "X-Hypert-Content-Encoding: " + tc.encoding} {
+				if !strings.Contains(stored, expected) {
+					t.Errorf("expected stored response to contain %q, got:\n%s", expected, stored)
+				}
+			}
+			if strings.Contains(stored, "\r\nContent-Encoding:") {
+				t.Errorf("expected Content-Encoding to be removed, got:\n%s", stored)
+			}
+			srv.Close()
+			get(t, ModeReplay)
+		})
+	}
+}
+
+func TestDecodedBodies_NotRegisteredEncoding(t *testing.T) {
+	store := NewMemoryStore()
+	resp := "HTTP/1.1 200 OK\r\nContent-Length: 2\r\nX-Hypert-Content-Encoding: br\r\n\r\n{}"
+	if err := store.WriteResponse("0.resp.http", strings.NewReader(resp)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	transport := replayTransport{store: store, codings: defaultContentCodings()}
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Accept-Encoding", "br")
+	_, err = transport.readRespFromFile("0.resp.http", req) //nolint:bodyclose // the response is not returned
+	if err == nil || !strings.Contains(err.Error(), "WithContentCoding") {
+		t.Errorf("expected error pointing to WithContentCoding option, got %v", err)
+	}
+}
+
+func TestDecodedBodies_NotRegisteredEncodingStoredAsIs(t *testing.T) {
+	const encoded = "\x1b\x00\xf8\xa5"
+	resp := newTestResponse(encoded)
+	resp.Header.Set("Content-Encoding", "br")
+	mT := &mockT{T: t}
+	store := NewMemoryStore()
+	rt := recordTransport{
+		t:             mT,
+		httpTransport: &mockRoundTripper{resp: resp}, //nolint:bodyclose // body is closed by the transport
+		namingScheme:  &staticNamingScheme{reqFile: "0.req.http", respFile: "0.resp.http"},
+		sanitizer:     NoOpRequestSanitizer{},
+		store:         store,
+		decoder:       defaultContentCodings(),
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	got, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected response with not registered encoding to be recorded, got %v", err)
+	}
+	got.Body.Close()
+	if mT.failed {
+		t.Errorf("unexpected test failure: %s", mT.msg)
+	}
+	stored := readStoreContent(t, store.OpenResponse, "0.resp.http")
+	if !strings.Contains(stored, "Content-Encoding: br\r\n") || !strings.HasSuffix(stored, encoded) {
+		t.Errorf("expected response to be stored encoded, got %q", stored)
+	}
+}
+
+func TestDecodedBodies_InvalidBodyFailsRecording(t *testing.T) {
+	resp := newTestResponse("not gzip")
+	resp.Header.Set("Content-Encoding", "gzip")
+	mT := &mockT{T: t}
+	store := NewMemoryStore()
+	rt := recordTransport{
+		t:             mT,
+		httpTransport: &mockRoundTripper{resp: resp}, //nolint:bodyclose // body is closed by the transport
+		namingScheme:  &staticNamingScheme{reqFile: "0.req.http", respFile: "0.resp.http"},
+		sanitizer:     NoOpRequestSanitizer{},
+		store:         store,
+		decoder:       defaultContentCodings(),
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	got, err := rt.RoundTrip(req) //nolint:bodyclose // response is not returned on error
+	if err == nil {
+		got.Body.Close()
+		t.Fatalf("expected error for invalid gzip body")
+	}
+	if !mT.fatal || !strings.Contains(mT.msg, "decode gzip body") {
+		t.Errorf("expected test to fail fatally because of the invalid body, got %q", mT.msg)
+	}
+	if _, err := store.OpenResponse("0.resp.http"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected response not to be stored, got %v", err)
+	}
+}
+
+func TestDecodedBodies_ReplayWithoutAcceptEncoding(t *testing.T) {
+	store := NewMemoryStore()
+	resp := "HTTP/1.1 200 OK\r\nContent-Length: 2\r\nX-Hypert-Content-Encoding: gzip\r\n\r\n{}"
+	if err := store.WriteResponse("0.resp.http", strings.NewReader(resp)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	transport := replayTransport{store: store, codings: defaultContentCodings()}
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	got, err := transport.readRespFromFile("0.resp.http", req)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	defer got.Body.Close()
+	body, err := io.ReadAll(got.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if got.Header.Get("Content-Encoding") != "" || string(body) != "{}" {
+		t.Errorf("expected decoded body without Content-Encoding, got %q %q", got.Header.Get("Content-Encoding"), body)
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	testCases := []struct {
+		acceptEncoding string
+		encoding       string
+		expected       bool
+	}{
+		{acceptEncoding: "", encoding: "gzip", expected: false},
+		{acceptEncoding: "gzip", encoding: "gzip", expected: true},
+		{acceptEncoding: "deflate, GZIP;q=0.5", encoding: "gzip", expected: true},
+		{acceptEncoding: "br", encoding: "gzip", expected: false},
+		{acceptEncoding: "gzip;q=0", encoding: "gzip", expected: false},
+		{acceptEncoding: "*", encoding: "br", expected: true},
+		{acceptEncoding: "*, br;q=0", encoding: "br", expected: false},
+	}
+	for _, tc := range testCases {
+		if got := acceptsEncoding(tc.acceptEncoding, tc.encoding); got != tc.expected {
+			t.Errorf("expected acceptsEncoding(%q, %q) to be %v, got %v", tc.acceptEncoding, tc.encoding, tc.expected, got)
+		}
+	}
+}
+
+func encodeTestBody(t *testing.T, coding ContentCoding, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := coding.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	if _, err := io.WriteString(w, body); err != nil {
+		t.Fatalf("failed to encode body: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to encode body: %v", err)
+	}
+	return buf.Bytes()
+}
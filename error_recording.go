@@ -0,0 +1,234 @@
+package hypert
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// WithRecordedErrors makes TestClient store the transport errors of the failed calls in record mode, e.g. timeouts or connection resets,
+// instead of the responses. In replay mode, an equivalent RecordedError is returned, so that retry and failover logic can be tested.
+//
+// Error recordings are stored in the response files in HYPERT-ERROR/1 format, e.g.
+//
+//	HYPERT-ERROR/1
+//	Kind: connection-reset
+//	Message: read tcp 127.0.0.1:51000->127.0.0.1:8080: read: connection reset by peer
+//	Op: read
+//
+// so they can be also written by hand, to simulate the failures, that are hard to trigger.
+func WithRecordedErrors() Option {
+	return func(cfg *config) {
+		cfg.recordErrors = true
+	}
+}
+
+// ErrorKind describes the cause of the recorded transport error.
+type ErrorKind string
+
+const (
+	// ErrorKindTimeout is a network timeout, e.g. http.Transport's ResponseHeaderTimeout. Replayed error is a net.Error with Timeout() true,
+	// wrapping os.ErrDeadlineExceeded.
+	ErrorKindTimeout ErrorKind = "timeout"
+	// ErrorKindDeadlineExceeded is a request context's deadline. Replayed error wraps context.DeadlineExceeded.
+	ErrorKindDeadlineExceeded ErrorKind = "deadline-exceeded"
+	// ErrorKindCanceled is a request context's cancellation. Replayed error wraps context.Canceled.
+	ErrorKindCanceled ErrorKind = "canceled"
+	// ErrorKindConnectionReset is a connection reset by peer. Replayed error is *net.OpError wrapping syscall.ECONNRESET.
+	ErrorKindConnectionReset ErrorKind = "connection-reset"
+	// ErrorKindConnectionRefused is a refused connection. Replayed error is *net.OpError wrapping syscall.ECONNREFUSED.
+	ErrorKindConnectionRefused ErrorKind = "connection-refused"
+	// ErrorKindDNS is a failed host lookup. Replayed error is *net.OpError wrapping *net.DNSError.
+	ErrorKindDNS ErrorKind = "dns"
+	// ErrorKindTLS is a failed TLS handshake, e.g. because of invalid certificate. Replayed error has only the recorded message.
+	ErrorKindTLS ErrorKind = "tls"
+	// ErrorKindEOF is a connection closed before the response was sent. Replayed error wraps io.EOF.
+	ErrorKindEOF ErrorKind = "eof"
+	// ErrorKindUnexpectedEOF is a connection closed in the middle of the response. Replayed error wraps io.ErrUnexpectedEOF.
+	ErrorKindUnexpectedEOF ErrorKind = "unexpected-eof"
+	// ErrorKindOther is any other error. Replayed error has only the recorded message.
+	ErrorKindOther ErrorKind = "other"
+)
+
+// RecordedError is returned in replay mode instead of the response, if the recorded call failed, see WithRecordedErrors.
+// It wraps the error equivalent to the recorded one, so that errors.Is and errors.As work the same way, as they did in record mode.
+// It implements net.Error.
+type RecordedError struct {
+	Kind    ErrorKind
+	Message string
+	err     error
+}
+
+func (e *RecordedError) Error() string {
+	return e.Message
+}
+
+func (e *RecordedError) Unwrap() error {
+	return e.err
+}
+
+// Timeout is true for ErrorKindTimeout and ErrorKindDeadlineExceeded errors.
+func (e *RecordedError) Timeout() bool {
+	return e.Kind == ErrorKindTimeout || e.Kind == ErrorKindDeadlineExceeded
+}
+
+// Temporary is the same as Timeout, following os.ErrDeadlineExceeded.
+func (e *RecordedError) Temporary() bool {
+	return e.Timeout()
+}
+
+const (
+	errorRecordingMagic = "HYPERT-ERROR/1"
+
+	errorKindField        = "Kind"
+	errorMessageField     = "Message"
+	errorOpField          = "Op"
+	errorDNSNameField     = "Dns-Name"
+	errorDNSErrField      = "Dns-Err"
+	errorDNSNotFoundField = "Dns-Not-Found"
+)
+
+// errorRecordingFields returns the fields describing the transport error, that are stored in the error recording.
+func errorRecordingFields(err error) http.Header {
+	fields := http.Header{}
+	kind := classifyError(err)
+	fields.Set(errorKindField, string(kind))
+	fields.Set(errorMessageField, err.Error())
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		fields.Set(errorOpField, opErr.Op)
+	}
+	var dnsErr *net.DNSError
+	if kind == ErrorKindDNS && errors.As(err, &dnsErr) {
+		fields.Set(errorDNSNameField, dnsErr.Name)
+		fields.Set(errorDNSErrField, dnsErr.Err)
+		fields.Set(errorDNSNotFoundField, strconv.FormatBool(dnsErr.IsNotFound))
+	}
+	return fields
+}
+
+func classifyError(err error) ErrorKind {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorKindCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorKindDeadlineExceeded
+	case errors.Is(err, syscall.ECONNRESET):
+		return ErrorKindConnectionReset
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorKindConnectionRefused
+	case errors.As(err, &dnsErr):
+		return ErrorKindDNS
+	case isTLSError(err):
+		return ErrorKindTLS
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorKindUnexpectedEOF
+	case errors.Is(err, io.EOF):
+		return ErrorKindEOF
+	case errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return ErrorKindTimeout
+	default:
+		return ErrorKindOther
+	}
+}
+
+func isTLSError(err error) bool {
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+	var recordHeaderErr tls.RecordHeaderError
+	return errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &certificateInvalidErr) ||
+		errors.As(err, &recordHeaderErr) ||
+		strings.Contains(err.Error(), "tls: ")
+}
+
+// writeErrorRecording writes the error recording with given fields.
+func writeErrorRecording(w io.Writer, fields http.Header) error {
+	if _, err := io.WriteString(w, errorRecordingMagic+"\r\n"); err != nil {
+		return err
+	}
+	if err := fields.Write(w); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+// isErrorRecording is true, if the stored response is an error recording.
+func isErrorRecording(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(errorRecordingMagic+"\r\n")) || bytes.HasPrefix(content, []byte(errorRecordingMagic+"\n"))
+}
+
+// readErrorRecording returns the fields of the error recording.
+func readErrorRecording(r io.Reader) (http.Header, error) {
+	tp := textproto.NewReader(bufio.NewReader(r))
+	magic, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	if magic != errorRecordingMagic {
+		return nil, fmt.Errorf("unsupported error recording version '%s'", magic)
+	}
+	fields, err := tp.ReadMIMEHeader()
+	if err != nil && !(errors.Is(err, io.EOF) && len(fields) > 0) {
+		return nil, err
+	}
+	return http.Header(fields), nil
+}
+
+// recordedError returns the error equivalent to the one described by the fields of the error recording.
+func recordedError(fields http.Header) *RecordedError {
+	kind := ErrorKind(fields.Get(errorKindField))
+	op := fields.Get(errorOpField)
+	opError := func(defaultOp string, err error) error {
+		if op == "" {
+			op = defaultOp
+		}
+		return &net.OpError{Op: op, Net: "tcp", Err: err}
+	}
+	equivalents := map[ErrorKind]func() error{
+		ErrorKindTimeout:          func() error { return opError("read", os.ErrDeadlineExceeded) },
+		ErrorKindDeadlineExceeded: func() error { return context.DeadlineExceeded },
+		ErrorKindCanceled:         func() error { return context.Canceled },
+		ErrorKindConnectionReset: func() error {
+			return opError("read", os.NewSyscallError("read", syscall.ECONNRESET))
+		},
+		ErrorKindConnectionRefused: func() error {
+			return opError("dial", os.NewSyscallError("connect", syscall.ECONNREFUSED))
+		},
+		ErrorKindDNS: func() error {
+			notFound, _ := strconv.ParseBool(fields.Get(errorDNSNotFoundField))
+			return opError("dial", &net.DNSError{
+				Err:        fields.Get(errorDNSErrField),
+				Name:       fields.Get(errorDNSNameField),
+				IsNotFound: notFound,
+			})
+		},
+		ErrorKindEOF:           func() error { return io.EOF },
+		ErrorKindUnexpectedEOF: func() error { return io.ErrUnexpectedEOF },
+	}
+	recorded := &RecordedError{Kind: kind, Message: fields.Get(errorMessageField)}
+	if equivalent, ok := equivalents[kind]; ok {
+		recorded.err = equivalent()
+	}
+	if recorded.Message == "" && recorded.err != nil {
+		recorded.Message = recorded.err.Error()
+	}
+	return recorded
+}
@@ -0,0 +1,148 @@
+package hypert
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestErrorRecording(t *testing.T) {
+	testCases := []struct {
+		name         string
+		err          error
+		expectedKind ErrorKind
+		check        func(err error) bool
+	}{
+		{
+			name:         "connection reset",
+			err:          &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+			expectedKind: ErrorKindConnectionReset,
+			check: func(err error) bool {
+				var opErr *net.OpError
+				return errors.Is(err, syscall.ECONNRESET) && errors.As(err, &opErr) && opErr.Op == "read"
+			},
+		},
+		{
+			name:         "connection refused",
+			err:          &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			expectedKind: ErrorKindConnectionRefused,
+			check:        func(err error) bool { return errors.Is(err, syscall.ECONNREFUSED) },
+		},
+		{
+			name:         "timeout",
+			err:          &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded},
+			expectedKind: ErrorKindTimeout,
+			check: func(err error) bool {
+				var netErr net.Error
+				return errors.As(err, &netErr) && netErr.Timeout() && errors.Is(err, os.ErrDeadlineExceeded)
+			},
+		},
+		{
+			name:         "context deadline",
+			err:          context.DeadlineExceeded,
+			expectedKind: ErrorKindDeadlineExceeded,
+			check:        func(err error) bool { return errors.Is(err, context.DeadlineExceeded) },
+		},
+		{
+			name:         "context canceled",
+			err:          context.Canceled,
+			expectedKind: ErrorKindCanceled,
+			check:        func(err error) bool { return errors.Is(err, context.Canceled) },
+		},
+		{
+			name:         "dns",
+			err:          &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "api.invalid", IsNotFound: true}},
+			expectedKind: ErrorKindDNS,
+			check: func(err error) bool {
+				var dnsErr *net.DNSError
+				return errors.As(err, &dnsErr) && dnsErr.Name == "api.invalid" && dnsErr.IsNotFound
+			},
+		},
+		{
+			name:         "tls",
+			err:          errors.New("tls: failed to verify certificate: x509: certificate signed by unknown authority"),
+			expectedKind: ErrorKindTLS,
+			check:        func(err error) bool { return true },
+		},
+		{
+			name:         "unexpected EOF",
+			err:          io.ErrUnexpectedEOF,
+			expectedKind: ErrorKindUnexpectedEOF,
+			check:        func(err error) bool { return errors.Is(err, io.ErrUnexpectedEOF) },
+		},
+		{
+			name:         "other",
+			err:          errors.New("proxy said no\nreally"),
+			expectedKind: ErrorKindOther,
+			check:        func(err error) bool { return true },
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf strings.Builder
+			if err := writeErrorRecording(&buf, errorRecordingFields(tc.err)); err != nil {
+				t.Fatalf("failed to write error recording: %v", err)
+			}
+			if !isErrorRecording([]byte(buf.String())) {
+				t.Fatalf("expected error recording, got:\n%s", buf.String())
+			}
+			fields, err := readErrorRecording(strings.NewReader(buf.String()))
+			if err != nil {
+				t.Fatalf("failed to read error recording: %v", err)
+			}
+			recorded := recordedError(fields)
+			if recorded.Kind != tc.expectedKind {
+				t.Errorf("expected kind %s, got %s", tc.expectedKind, recorded.Kind)
+			}
+			if expected := strings.ReplaceAll(tc.err.Error(), "\n", " "); recorded.Error() != expected {
+				t.Errorf("expected message %q, got %q", expected, recorded.Error())
+			}
+			if !tc.check(recorded) {
+				t.Errorf("replayed error %#v is not equivalent to %#v", recorded, tc.err)
+			}
+		})
+	}
+}
+
+func TestRecordedErrors_RecordAndReplay(t *testing.T) {
+	stores := map[string]Store{
+		"memory":   NewMemoryStore(),
+		"cassette": NewCassetteStore(filepath.Join(t.TempDir(), "errors"+cassetteExt)),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			callErr := &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}
+			get := func(t *testing.T, mode Mode) error {
+				client := TestClient(t, false,
+					WithMode(mode),
+					WithStore(store),
+					WithNamingScheme(&SequentialNamingScheme{dir: "errors"}),
+					WithParentHTTPClient(&http.Client{Transport: &mockRoundTripper{err: callErr}}),
+					WithRecordedErrors(),
+				)
+				_, err := client.Get("https://example.com/flaky") //nolint:bodyclose // the call fails
+				return err
+			}
+
+			recordErr := get(t, ModeRecord)
+			if !errors.Is(recordErr, os.ErrDeadlineExceeded) {
+				t.Fatalf("expected the call to fail with the transport error, got %v", recordErr)
+			}
+			replayErr := get(t, ModeReplay)
+			var netErr net.Error
+			if !errors.As(replayErr, &netErr) || !netErr.Timeout() {
+				t.Errorf("expected timeout error in replay mode, got %v", replayErr)
+			}
+			if replayErr == nil || replayErr.Error() != recordErr.Error() {
+				t.Errorf("expected replayed error to be the same as the recorded one %q, got %v", recordErr, replayErr)
+			}
+		})
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"context"
 	"github.com/areknoster/hypert"
+	"github.com/areknoster/hypert/aws"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"testing"
 )
@@ -26,21 +29,17 @@ func setupDownloader(t *testing.T, recordModeOn bool) *OldestObjectDownloader {
 	hypertClient := hypert.TestClient(
 		t,
 		recordModeOn,
-		hypert.WithRequestSanitizer(hypert.HeadersSanitizer("Authorization", "X-Amz-Security-Token")),
-		hypert.WithRequestValidator(
-			hypert.ComposedRequestValidator(
-				hypert.PathValidator(),
-				hypert.MethodValidator(),
-				hypert.QueryParamsValidator(),
-			),
-		),
+		hypert.WithRequestSanitizer(aws.DefaultRequestSanitizer()),
+		hypert.WithRequestValidator(aws.RequestValidator()),
 	)
 
 	var s3Client *s3.Client
 	if !recordModeOn {
+		// dummy credentials are enough to sign the requests, so that the signed region and service are validated
 		s3Client = s3.New(s3.Options{
-			Region:     "us-east-1",
-			HTTPClient: hypertClient,
+			Region:      "us-east-1",
+			HTTPClient:  hypertClient,
+			Credentials: awssdk.NewCredentialsCache(credentials.NewStaticCredentialsProvider("AKID", "SECRET", "")),
 		})
 	} else {
 		cfg, err := config.LoadDefaultConfig(context.TODO())
@@ -0,0 +1,180 @@
+package hypert
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ValueComparator reports, whether the value of the request made in replay mode matches the recorded one.
+// It's used with WithFieldComparator for the values, that change on every call, e.g. request IDs or timestamps.
+type ValueComparator func(recorded, got string) bool
+
+// uuidRegexp matches UUIDs in canonical textual representation, regardless of the version.
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// AnyUUID accepts any UUID, e.g. for X-Request-Id or Idempotency-Key headers.
+func AnyUUID() ValueComparator {
+	return AnyMatching(uuidRegexp)
+}
+
+// AnyRFC3339 accepts any RFC3339 timestamp, e.g. "2024-04-02T15:04:05Z".
+func AnyRFC3339() ValueComparator {
+	return func(_, got string) bool {
+		_, err := time.Parse(time.RFC3339, got)
+		return err == nil
+	}
+}
+
+// AnyMatching accepts any value matching the regular expression. Use anchors to match the whole value.
+func AnyMatching(re *regexp.Regexp) ValueComparator {
+	return func(_, got string) bool {
+		return re.MatchString(got)
+	}
+}
+
+type fieldValidatorConfig struct {
+	ignored         map[string]struct{}
+	ignoredPatterns []*regexp.Regexp
+	comparators     map[string]ValueComparator
+	// canonicalName is applied to the names passed in options, e.g. http.CanonicalHeaderKey for headers.
+	canonicalName func(string) string
+}
+
+// FieldValidatorOption can be used to customize HeadersValidator and QueryParamsValidator behaviour.
+type FieldValidatorOption func(*fieldValidatorConfig)
+
+// WithIgnoredFields removes the headers or query parameters with given names from the comparison.
+// Header names are case-insensitive, query parameter names are not.
+func WithIgnoredFields(names ...string) FieldValidatorOption {
+	return func(cfg *fieldValidatorConfig) {
+		for _, name := range names {
+			cfg.ignored[cfg.canonicalName(name)] = struct{}{}
+		}
+	}
+}
+
+// WithIgnoredFieldPatterns removes the headers or query parameters with names matching any of the patterns from the comparison, e.g.
+//
+//	HeadersValidator(WithIgnoredFieldPatterns(regexp.MustCompile(`^X-Trace-`)))
+//
+// Header names are matched in canonical form, as returned by http.CanonicalHeaderKey.
+func WithIgnoredFieldPatterns(patterns ...*regexp.Regexp) FieldValidatorOption {
+	return func(cfg *fieldValidatorConfig) {
+		cfg.ignoredPatterns = append(cfg.ignoredPatterns, patterns...)
+	}
+}
+
+// WithFieldComparator sets the comparator used for the values of the header or query parameter with given name, e.g.
+//
+//	HeadersValidator(
+//		WithFieldComparator("X-Request-Id", AnyUUID()),
+//		WithFieldComparator("X-Timestamp", AnyRFC3339()),
+//	)
+//
+// The field still has to be present in both requests with the same number of values.
+func WithFieldComparator(name string, cmp ValueComparator) FieldValidatorOption {
+	return func(cfg *fieldValidatorConfig) {
+		cfg.comparators[cfg.canonicalName(name)] = cmp
+	}
+}
+
+func newFieldValidatorConfig(canonicalName func(string) string, opts []FieldValidatorOption) *fieldValidatorConfig {
+	cfg := &fieldValidatorConfig{
+		ignored:       make(map[string]struct{}),
+		comparators:   make(map[string]ValueComparator),
+		canonicalName: canonicalName,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+func (cfg *fieldValidatorConfig) isIgnored(name string) bool {
+	if _, ok := cfg.ignored[name]; ok {
+		return true
+	}
+	for _, re := range cfg.ignoredPatterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// diff compares all the values of the fields, e.g. headers or query parameters, keyed by their canonical names.
+// The fields, which recorded values are all "SANITIZED", match any values or their absence, e.g. when the credentials are not set in replay mode,
+// and single "SANITIZED" values match any value at their position.
+func (cfg *fieldValidatorConfig) diff(kind string, recorded, got map[string][]string) []Mismatch {
+	var mismatches []Mismatch
+	for name, recordedValues := range recorded {
+		if cfg.isIgnored(name) {
+			continue
+		}
+		gotValues, present := got[name]
+		if !present && isSanitized(recordedValues) {
+			continue
+		}
+		if !present {
+			mismatches = append(mismatches, Mismatch{Field: fieldName(kind, name), Expected: formatValues(recordedValues), Got: Missing})
+			continue
+		}
+		if !cfg.valuesMatch(name, recordedValues, gotValues) {
+			mismatches = append(mismatches, Mismatch{Field: fieldName(kind, name), Expected: formatValues(recordedValues), Got: formatValues(gotValues)})
+		}
+	}
+	for name, gotValues := range got {
+		if _, ok := recorded[name]; ok || cfg.isIgnored(name) {
+			continue
+		}
+		mismatches = append(mismatches, Mismatch{Field: fieldName(kind, name), Expected: Missing, Got: formatValues(gotValues)})
+	}
+	sortMismatches(mismatches)
+	return mismatches
+}
+
+func (cfg *fieldValidatorConfig) valuesMatch(name string, recorded, got []string) bool {
+	if isSanitized(recorded) {
+		return true
+	}
+	if len(recorded) != len(got) {
+		return false
+	}
+	cmp, ok := cfg.comparators[name]
+	if !ok {
+		cmp = func(recorded, got string) bool { return recorded == got }
+	}
+	for i := range recorded {
+		if recorded[i] != sanitizedValue && !cmp(recorded[i], got[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isSanitized(values []string) bool {
+	for _, v := range values {
+		if v != sanitizedValue {
+			return false
+		}
+	}
+	return len(values) > 0
+}
+
+func fieldName(kind, name string) string {
+	return fmt.Sprintf("%s '%s'", kind, name)
+}
+
+// formatValues formats single value as 'value', and multiple ones as ['a', 'b'].
+func formatValues(values []string) string {
+	if len(values) == 1 {
+		return quoted(values[0])
+	}
+	formatted := make([]string, 0, len(values))
+	for _, v := range values {
+		formatted = append(formatted, quoted(v))
+	}
+	return "[" + strings.Join(formatted, ", ") + "]"
+}
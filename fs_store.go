@@ -0,0 +1,90 @@
+package hypert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+)
+
+// WithReplayFS makes TestClient replay the recordings from given file system, e.g. embed.FS with the testdata directory.
+// It's useful, when the tests are run from a binary, where the source tree is not available.
+//
+// The recordings are looked up in <root>/<test name> directory of the file system, with the names relative to it,
+// so for the default layout, the root should be "testdata" and the file system should embed it, e.g.:
+//
+//	//go:embed testdata
+//	var testdata embed.FS
+//
+//	httpClient := hypert.TestClient(t, false, hypert.WithReplayFS(testdata, "testdata"))
+//
+// If custom NamingScheme is set, it should return the names relative to the file system.
+// It's replay only option, so the test fails, if it's used in any other mode.
+func WithReplayFS(fsys fs.FS, root string) Option {
+	return func(cfg *config) {
+		cfg.replayFS = fsys
+		cfg.replayFSRoot = root
+	}
+}
+
+// FSStore is a read-only Store, that reads the recordings from fs.FS. All the writes fail with ErrReadOnlyStore.
+// It should be initialized using NewFSStore function.
+type FSStore struct {
+	fsys fs.FS
+	dir  string
+}
+
+// NewFSStore initializes FSStore, that implements Store interface.
+// 'dir' parameter indicates, in which directory of the file system the recordings are listed.
+func NewFSStore(fsys fs.FS, dir string) *FSStore {
+	return &FSStore{fsys: fsys, dir: dir}
+}
+
+func (s *FSStore) WriteRequest(name string, _ io.Reader) error {
+	return fmt.Errorf("write request %s: %w", name, ErrReadOnlyStore)
+}
+
+func (s *FSStore) WriteResponse(name string, _ io.Reader) error {
+	return fmt.Errorf("write response %s: %w", name, ErrReadOnlyStore)
+}
+
+func (s *FSStore) OpenRequest(name string) (io.ReadCloser, error) {
+	return s.fsys.Open(filepath.ToSlash(name))
+}
+
+func (s *FSStore) OpenResponse(name string) (io.ReadCloser, error) {
+	return s.fsys.Open(filepath.ToSlash(name))
+}
+
+func (s *FSStore) WriteBody(name string, _ io.Reader) error {
+	return fmt.Errorf("write body %s: %w", name, ErrReadOnlyStore)
+}
+
+func (s *FSStore) OpenBody(name string) (io.ReadCloser, error) {
+	return s.fsys.Open(filepath.ToSlash(name))
+}
+
+// List returns the recordings following <name>.req.http, <name>.resp.http convention from the store's directory,
+// sorted the same way FileStore does it.
+func (s *FSStore) List() ([]Recording, error) {
+	entries, err := fs.ReadDir(s.fsys, s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read directory %s: %w", s.dir, err)
+	}
+	var recordings []Recording
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if r, ok := recordingPair(path.Join(s.dir, entry.Name())); ok {
+			recordings = append(recordings, r)
+		}
+	}
+	sortRecordings(recordings)
+	return recordings, nil
+}
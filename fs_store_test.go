@@ -0,0 +1,60 @@
+package hypert
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWithReplayFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"testdata/TestWithReplayFS/0.req.http": &fstest.MapFile{
+			Data: []byte("GET https://example.com/embedded HTTP/1.1\r\nHost: example.com\r\n\r\n"),
+		},
+		"testdata/TestWithReplayFS/0.resp.http": &fstest.MapFile{
+			Data: []byte("HTTP/1.1 200 OK\r\nContent-Length: 8\r\n\r\nembedded"),
+		},
+	}
+
+	c := TestClient(t, false, WithReplayFS(fsys, "testdata"), WithUnusedRecordingsCheck(UnusedRecordingsError))
+	resp, err := c.Get("https://example.com/embedded")
+	if err != nil {
+		t.Fatalf("failed to replay: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if string(body) != "embedded" {
+		t.Errorf("expected body %q, got %q", "embedded", body)
+	}
+}
+
+func TestWithReplayFS_RecordModeFails(t *testing.T) {
+	mT := &mockT{T: t}
+	configWithDefaults(mT, true, []Option{WithReplayFS(fstest.MapFS{}, "testdata")})
+	if !mT.fatal {
+		t.Error("expected replay file system to fail in record mode")
+	}
+}
+
+func TestFSStore(t *testing.T) {
+	store := NewFSStore(fstest.MapFS{
+		"dir/0.req.http":  &fstest.MapFile{},
+		"dir/0.resp.http": &fstest.MapFile{},
+	}, "dir")
+
+	recordings, err := store.List()
+	if err != nil {
+		t.Fatalf("failed to list recordings: %v", err)
+	}
+	if len(recordings) != 1 || recordings[0] != (Recording{Request: "dir/0.req.http", Response: "dir/0.resp.http"}) {
+		t.Errorf("unexpected recordings: %v", recordings)
+	}
+	if err := store.WriteRequest("dir/1.req.http", strings.NewReader("")); !errors.Is(err, ErrReadOnlyStore) {
+		t.Errorf("expected ErrReadOnlyStore, got %v", err)
+	}
+}
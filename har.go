@@ -0,0 +1,349 @@
+package hypert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// har is the root of HTTP Archive 1.2 document, see http://www.softwareishard.com/blog/har-12-spec/
+type har struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+	// Error is not a part of HAR 1.2 spec, it's the message of the recorded transport error, as exported by browsers for failed requests
+	Error string `json:"_error,omitempty"`
+	// ErrorKind is the ErrorKind of the recorded transport error, so that it's kept when the HAR is imported back
+	ErrorKind string `json:"_errorKind,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string         `json:"mimeType"`
+	Params   []harNameValue `json:"params,omitempty"`
+	Text     string         `json:"text"`
+	// Encoding is not a part of HAR 1.2 spec, it's used to keep binary request bodies as base64
+	Encoding string `json:"_encoding,omitempty"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// ExportHAR writes the recordings kept in the store to w as HTTP Archive (HAR) 1.2 document,
+// so that they can be inspected in browser devtools or other HAR viewers.
+// The recordings of a test stored with the default settings can be exported with NewFileStore("testdata/<test name>").
+//
+// The entries are in the order returned by the store's List method, and their start time is taken from the response's Date header, if it's present.
+// The error recordings, see WithRecordedErrors, are exported with response status 0, the error message in "_error" field
+// and the error kind in "_errorKind" field.
+func ExportHAR(store Store, w io.Writer) error {
+	recordings, err := store.List()
+	if err != nil {
+		return fmt.Errorf("list recordings: %w", err)
+	}
+
+	doc := har{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "hypert", Version: "1"},
+		Entries: make([]harEntry, 0, len(recordings)),
+	}}
+	for _, r := range recordings {
+		entry, err := harEntryFromRecording(store, r)
+		if err != nil {
+			return err
+		}
+		doc.Log.Entries = append(doc.Log.Entries, entry)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("encode HAR: %w", err)
+	}
+	return nil
+}
+
+func harEntryFromRecording(store Store, r Recording) (harEntry, error) {
+	req, reqBody, err := readStoredRequest(store, r.Request)
+	if err != nil {
+		return harEntry{}, err
+	}
+	resp, respBody, err := readStoredResponse(store, r.Response, req)
+	var recordedErr *RecordedError
+	if errors.As(err, &recordedErr) {
+		return harEntry{
+			StartedDateTime: time.Unix(0, 0).UTC().Format(time.RFC3339Nano),
+			Request:         harRequestFromRequest(req, reqBody),
+			Response:        harResponseFromError(recordedErr),
+		}, nil
+	}
+	if err != nil {
+		return harEntry{}, err
+	}
+
+	started := time.Unix(0, 0).UTC()
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		started = date
+	}
+	return harEntry{
+		StartedDateTime: started.Format(time.RFC3339Nano),
+		Request:         harRequestFromRequest(req, reqBody),
+		Response:        harResponseFromResponse(resp, respBody),
+	}, nil
+}
+
+func readStoredRequest(store Store, name string) (*http.Request, []byte, error) {
+	f, err := store.OpenRequest(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open request %s: %w", name, err)
+	}
+	defer f.Close()
+	req, err := http.ReadRequest(bufio.NewReader(f))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read request %s: %w", name, err)
+	}
+	body, err := readStoredBody(store, name, req.Header, req.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read request %s body: %w", name, err)
+	}
+	return req, body, nil
+}
+
+// readStoredResponse returns the stored response. If it's an error recording, see WithRecordedErrors, *RecordedError is returned.
+func readStoredResponse(store Store, name string, req *http.Request) (*http.Response, []byte, error) {
+	f, err := store.OpenResponse(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open response %s: %w", name, err)
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read response %s: %w", name, err)
+	}
+	if isErrorRecording(content) {
+		fields, err := readErrorRecording(bytes.NewReader(content))
+		if err != nil {
+			return nil, nil, fmt.Errorf("read error recording %s: %w", name, err)
+		}
+		return nil, nil, recordedError(fields)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(content)), req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read response %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	body, err := readStoredBody(store, name, resp.Header, resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read response %s body: %w", name, err)
+	}
+	return resp, body, nil
+}
+
+// readStoredBody returns the body of the stored request or response, reading it from the sidecar file, if it's stored there,
+// see WithSidecarBodies and WithBodyStreaming. The headers internal to hypert are removed from h,
+// and the original Content-Encoding of the body stored decoded is restored, see WithDecodedBodies.
+func readStoredBody(store Store, name string, h http.Header, inline io.Reader) ([]byte, error) {
+	ref, sidecar, err := takeBodyFileRef(h, name)
+	if err != nil {
+		return nil, err
+	}
+	if encoding := h.Get(decodedEncodingHeader); encoding != "" {
+		h.Del(decodedEncodingHeader)
+		h.Set("Content-Encoding", encoding)
+	}
+	if !sidecar {
+		return io.ReadAll(inline)
+	}
+	body, err := readSidecarBody(store, ref)
+	if err != nil {
+		return nil, err
+	}
+	// the length might have changed, if the sidecar file was edited
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	return body, nil
+}
+
+func harHeaders(h http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for _, name := range sortedKeys(h) {
+		for _, value := range h[name] {
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func harRequestFromRequest(req *http.Request, body []byte) harRequest {
+	headers := req.Header.Clone()
+	if req.Host != "" {
+		headers.Set("Host", req.Host)
+	}
+	cookies := []harNameValue{}
+	for _, c := range req.Cookies() {
+		cookies = append(cookies, harNameValue{Name: c.Name, Value: c.Value})
+	}
+	query := []harNameValue{}
+	q := req.URL.Query()
+	for _, name := range sortedKeys(q) {
+		for _, value := range q[name] {
+			query = append(query, harNameValue{Name: name, Value: value})
+		}
+	}
+	u := *req.URL
+	if u.Host == "" {
+		u.Host = req.Host
+	}
+
+	hr := harRequest{
+		Method:      req.Method,
+		URL:         u.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     cookies,
+		Headers:     harHeaders(headers),
+		QueryString: query,
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	if len(body) > 0 {
+		hr.PostData = harPostDataFromBody(req.Header.Get("Content-Type"), body)
+	}
+	return hr
+}
+
+func harPostDataFromBody(contentType string, body []byte) *harPostData {
+	postData := &harPostData{MimeType: contentType}
+	if !isPrintable(body) {
+		postData.Text = base64.StdEncoding.EncodeToString(body)
+		postData.Encoding = bodyEncodingB64
+		return postData
+	}
+	postData.Text = string(body)
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == "application/x-www-form-urlencoded" {
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			postData.Params = []harNameValue{}
+			for _, name := range sortedKeys(values) {
+				for _, value := range values[name] {
+					postData.Params = append(postData.Params, harNameValue{Name: name, Value: value})
+				}
+			}
+		}
+	}
+	return postData
+}
+
+func harResponseFromResponse(resp *http.Response, body []byte) harResponse {
+	cookies := []harNameValue{}
+	for _, c := range resp.Cookies() {
+		cookies = append(cookies, harNameValue{Name: c.Name, Value: c.Value})
+	}
+	content := harContent{
+		Size:     len(body),
+		MimeType: resp.Header.Get("Content-Type"),
+	}
+	if isPrintable(body) {
+		content.Text = string(body)
+	} else {
+		content.Text = base64.StdEncoding.EncodeToString(body)
+		content.Encoding = bodyEncodingB64
+	}
+	return harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Cookies:     cookies,
+		Headers:     harHeaders(resp.Header),
+		Content:     content,
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+}
+
+// harResponseFromError returns the response of the failed request, see WithRecordedErrors. Its status is 0, like in the HARs exported by browsers.
+func harResponseFromError(err *RecordedError) harResponse {
+	return harResponse{
+		Cookies:     []harNameValue{},
+		Headers:     []harNameValue{},
+		Content:     harContent{MimeType: "x-unknown"},
+		HeadersSize: -1,
+		BodySize:    -1,
+		Error:       err.Error(),
+		ErrorKind:   string(err.Kind),
+	}
+}
@@ -0,0 +1,297 @@
+package hypert
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type harImportConfig struct {
+	hosts         map[string]struct{}
+	path          *regexp.Regexp
+	sanitizer     RequestSanitizer
+	respSanitizer ResponseSanitizer
+	store         Store
+}
+
+// HARImportOption can be used to customize ImportHAR behaviour.
+type HARImportOption func(*harImportConfig)
+
+// WithHARHosts imports only the entries with requests to given hosts.
+func WithHARHosts(hosts ...string) HARImportOption {
+	return func(cfg *harImportConfig) {
+		if cfg.hosts == nil {
+			cfg.hosts = make(map[string]struct{})
+		}
+		for _, host := range hosts {
+			cfg.hosts[strings.ToLower(host)] = struct{}{}
+		}
+	}
+}
+
+// WithHARPath imports only the entries with request paths matching the regular expression.
+func WithHARPath(re *regexp.Regexp) HARImportOption {
+	return func(cfg *harImportConfig) {
+		cfg.path = re
+	}
+}
+
+// WithHARRequestSanitizer sets the sanitizer run over each imported request. By default, DefaultRequestSanitizer is used.
+// It should be the same as the one configured in TestClient, so that the imported requests can be replayed.
+func WithHARRequestSanitizer(s RequestSanitizer) HARImportOption {
+	return func(cfg *harImportConfig) {
+		cfg.sanitizer = s
+	}
+}
+
+// WithHARResponseSanitizer sets the sanitizer run over each imported response. By default, DefaultResponseSanitizer is used.
+func WithHARResponseSanitizer(s ResponseSanitizer) HARImportOption {
+	return func(cfg *harImportConfig) {
+		cfg.respSanitizer = s
+	}
+}
+
+// WithHARStore sets the Store, to which the imported requests and responses are written. By default, FileStore is used.
+func WithHARStore(s Store) HARImportOption {
+	return func(cfg *harImportConfig) {
+		cfg.store = s
+	}
+}
+
+// ImportHAR reads HTTP Archive (HAR) document, e.g. captured in browser devtools,
+// and writes its entries as hypert's recordings with names given by the naming scheme,
+// so that they can be replayed by TestClient configured with the same naming scheme.
+// It returns the number of imported entries.
+//
+// Response bodies in HAR files are already decoded, so Content-Encoding header is not imported.
+// The entries without the response, i.e. with status 0, are imported as error recordings, see WithRecordedErrors,
+// with the message taken from "_error" field, which browsers and ExportHAR set for the failed requests.
+func ImportHAR(r io.Reader, scheme NamingScheme, opts ...HARImportOption) (int, error) {
+	cfg := &harImportConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.sanitizer == nil {
+		cfg.sanitizer = DefaultRequestSanitizer()
+	}
+	if cfg.respSanitizer == nil {
+		cfg.respSanitizer = DefaultResponseSanitizer()
+	}
+	if cfg.store == nil {
+		cfg.store = NewFileStore("")
+	}
+
+	var doc har
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return 0, fmt.Errorf("decode HAR: %w", err)
+	}
+
+	imported := 0
+	for i, entry := range doc.Log.Entries {
+		req, err := requestFromHAR(entry.Request)
+		if err != nil {
+			return imported, fmt.Errorf("entry %d: %w", i, err)
+		}
+		if !cfg.matches(req) {
+			continue
+		}
+		if entry.Response.Status == 0 {
+			if err := importHARError(cfg, scheme, req, entry.Response); err != nil {
+				return imported, fmt.Errorf("entry %d: %w", i, err)
+			}
+			imported++
+			continue
+		}
+		resp, err := responseFromHAR(entry.Response)
+		if err != nil {
+			return imported, fmt.Errorf("entry %d: %w", i, err)
+		}
+		if err := importHAREntry(cfg, scheme, req, resp); err != nil {
+			return imported, fmt.Errorf("entry %d: %w", i, err)
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+func (cfg *harImportConfig) matches(req *http.Request) bool {
+	if cfg.hosts != nil {
+		if _, ok := cfg.hosts[strings.ToLower(req.URL.Hostname())]; !ok {
+			return false
+		}
+	}
+	if cfg.path != nil && !cfg.path.MatchString(req.URL.Path) {
+		return false
+	}
+	return true
+}
+
+func importHAREntry(cfg *harImportConfig, scheme NamingScheme, req *http.Request, resp *http.Response) error {
+	reqData, respFile, err := importHARRequest(cfg, scheme, req)
+	if err != nil {
+		return err
+	}
+
+	resp = cfg.respSanitizer.SanitizeResponse(resp)
+	var respBuf bytes.Buffer
+	if err := resp.Write(&respBuf); err != nil {
+		return fmt.Errorf("write response to %s: %w", reqData, err)
+	}
+	if err := cfg.store.WriteResponse(respFile, &respBuf); err != nil {
+		return fmt.Errorf("store response: %w", err)
+	}
+	return nil
+}
+
+// importHARError stores the request of the failed entry, and the error recording instead of the response.
+func importHARError(cfg *harImportConfig, scheme NamingScheme, req *http.Request, hr harResponse) error {
+	_, respFile, err := importHARRequest(cfg, scheme, req)
+	if err != nil {
+		return err
+	}
+	fields := http.Header{}
+	fields.Set(errorKindField, string(harErrorKind(hr)))
+	message := hr.Error
+	if message == "" {
+		message = "no response in HAR entry"
+	}
+	fields.Set(errorMessageField, message)
+	var buf bytes.Buffer
+	if err := writeErrorRecording(&buf, fields); err != nil {
+		return fmt.Errorf("write error recording %s: %w", respFile, err)
+	}
+	if err := cfg.store.WriteResponse(respFile, &buf); err != nil {
+		return fmt.Errorf("store error recording: %w", err)
+	}
+	return nil
+}
+
+// harBrowserErrorKinds maps the errors reported by Chromium-based browsers to the kinds of the recorded errors.
+var harBrowserErrorKinds = map[string]ErrorKind{
+	"net::ERR_ABORTED":              ErrorKindCanceled,
+	"net::ERR_CONNECTION_REFUSED":   ErrorKindConnectionRefused,
+	"net::ERR_CONNECTION_RESET":     ErrorKindConnectionReset,
+	"net::ERR_CONNECTION_TIMED_OUT": ErrorKindTimeout,
+	"net::ERR_TIMED_OUT":            ErrorKindTimeout,
+	"net::ERR_NAME_NOT_RESOLVED":    ErrorKindDNS,
+	"net::ERR_EMPTY_RESPONSE":       ErrorKindEOF,
+}
+
+// harErrorKind returns the kind of the failed entry's error. It's exported by ExportHAR, and guessed from the browsers' messages otherwise.
+func harErrorKind(hr harResponse) ErrorKind {
+	if hr.ErrorKind != "" {
+		return ErrorKind(hr.ErrorKind)
+	}
+	if kind, ok := harBrowserErrorKinds[hr.Error]; ok {
+		return kind
+	}
+	return ErrorKindOther
+}
+
+// importHARRequest stores the sanitized request and returns the name of the file, that the response should be stored in.
+func importHARRequest(cfg *harImportConfig, scheme NamingScheme, req *http.Request) (RequestData, string, error) {
+	sanitizedReq := cfg.sanitizer.SanitizeRequest(req)
+	// names are resolved from the sanitized request, the same way replay mode does it
+	reqData, err := requestDataFromRequest(sanitizedReq)
+	if err != nil {
+		return RequestData{}, "", fmt.Errorf("get request data: %w", err)
+	}
+	reqFile, respFile := scheme.FileNames(reqData)
+
+	var reqBuf bytes.Buffer
+	if err := sanitizedReq.WriteProxy(&reqBuf); err != nil {
+		return RequestData{}, "", fmt.Errorf("write request %s: %w", reqData, err)
+	}
+	if err := cfg.store.WriteRequest(reqFile, &reqBuf); err != nil {
+		return RequestData{}, "", fmt.Errorf("store request: %w", err)
+	}
+	return reqData, respFile, nil
+}
+
+// harSkippedHeaders are not imported, because they are set, when the request or response is written (Host and Content-Length),
+// or describe the encoding of the body, which is already decoded in HAR. HTTP/2 pseudo-headers, e.g. ":authority", are skipped as well.
+var harSkippedHeaders = map[string]struct{}{
+	"Host":              {},
+	"Content-Length":    {},
+	"Content-Encoding":  {},
+	"Transfer-Encoding": {},
+}
+
+func headersFromHAR(nameValues []harNameValue) http.Header {
+	h := http.Header{}
+	for _, nv := range nameValues {
+		if strings.HasPrefix(nv.Name, ":") {
+			continue
+		}
+		if _, ok := harSkippedHeaders[http.CanonicalHeaderKey(nv.Name)]; ok {
+			continue
+		}
+		h.Add(nv.Name, nv.Value)
+	}
+	return h
+}
+
+func harBody(text, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(text), nil
+	case bodyEncodingB64:
+		return base64.StdEncoding.DecodeString(text)
+	default:
+		return nil, fmt.Errorf("unknown body encoding '%s'", encoding)
+	}
+}
+
+func requestFromHAR(hr harRequest) (*http.Request, error) {
+	u, err := url.Parse(hr.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse request url: %w", err)
+	}
+	var body []byte
+	if hr.PostData != nil {
+		body, err = harBody(hr.PostData.Text, hr.PostData.Encoding)
+		if err != nil {
+			return nil, fmt.Errorf("decode request body: %w", err)
+		}
+	}
+	req, err := http.NewRequest(hr.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header = headersFromHAR(hr.Headers)
+	if hr.PostData != nil && hr.PostData.MimeType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", hr.PostData.MimeType)
+	}
+	return req, nil
+}
+
+func responseFromHAR(hr harResponse) (*http.Response, error) {
+	body, err := harBody(hr.Content.Text, hr.Content.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("decode response body: %w", err)
+	}
+	statusText := hr.StatusText
+	if statusText == "" {
+		statusText = http.StatusText(hr.Status)
+	}
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", hr.Status, statusText),
+		StatusCode:    hr.Status,
+		Header:        headersFromHAR(hr.Headers),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+	var ok bool
+	resp.ProtoMajor, resp.ProtoMinor, ok = http.ParseHTTPVersion(strings.ToUpper(hr.HTTPVersion))
+	if !ok {
+		// browsers report e.g. "h2" or "http/2.0", which can't be parsed as HTTP version
+		resp.ProtoMajor, resp.ProtoMinor = 1, 1
+	}
+	return resp, nil
+}
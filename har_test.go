@@ -0,0 +1,328 @@
+package hypert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestExportHAR(t *testing.T) {
+	store := NewMemoryStore()
+	writes := map[string]string{
+		"0.req.http": "POST https://example.com/login?redirect=home HTTP/1.1\r\n" +
+			"Host: example.com\r\n" +
+			"Content-Type: application/x-www-form-urlencoded\r\n" +
+			"Content-Length: 13\r\n\r\n" +
+			"user=a&pass=b",
+		"0.resp.http": "HTTP/1.1 200 OK\r\n" +
+			"Date: Mon, 02 Jan 2006 15:04:05 GMT\r\n" +
+			"Content-Type: application/json\r\n" +
+			"Set-Cookie: session=abc\r\n" +
+			"Content-Length: 11\r\n\r\n" +
+			`{"ok":true}`,
+	}
+	for _, name := range []string{"0.req.http", "0.resp.http"} {
+		if err := store.WriteRequest(name, strings.NewReader(writes[name])); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := ExportHAR(store, &buf); err != nil {
+		t.Fatalf("failed to export HAR: %v", err)
+	}
+	var doc har
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to parse exported HAR: %v", err)
+	}
+
+	if doc.Log.Version != "1.2" || len(doc.Log.Entries) != 1 {
+		t.Fatalf("expected HAR 1.2 with 1 entry, got version %s with %d entries", doc.Log.Version, len(doc.Log.Entries))
+	}
+	entry := doc.Log.Entries[0]
+	if entry.StartedDateTime != "2006-01-02T15:04:05Z" {
+		t.Errorf("expected start time from Date header, got %s", entry.StartedDateTime)
+	}
+	if entry.Request.Method != "POST" || entry.Request.URL != "https://example.com/login?redirect=home" {
+		t.Errorf("unexpected request: %s %s", entry.Request.Method, entry.Request.URL)
+	}
+	if len(entry.Request.QueryString) != 1 || entry.Request.QueryString[0] != (harNameValue{Name: "redirect", Value: "home"}) {
+		t.Errorf("unexpected query string: %v", entry.Request.QueryString)
+	}
+	if entry.Request.PostData == nil || entry.Request.PostData.MimeType != "application/x-www-form-urlencoded" ||
+		entry.Request.PostData.Text != "user=a&pass=b" || len(entry.Request.PostData.Params) != 2 {
+		t.Errorf("unexpected post data: %+v", entry.Request.PostData)
+	}
+	if entry.Response.Status != 200 || entry.Response.StatusText != "OK" {
+		t.Errorf("unexpected response status: %d %s", entry.Response.Status, entry.Response.StatusText)
+	}
+	if entry.Response.Content.Text != `{"ok":true}` || entry.Response.Content.MimeType != "application/json" {
+		t.Errorf("unexpected response content: %+v", entry.Response.Content)
+	}
+	if len(entry.Response.Cookies) != 1 || entry.Response.Cookies[0].Name != "session" {
+		t.Errorf("unexpected response cookies: %v", entry.Response.Cookies)
+	}
+}
+
+func TestImportHAR(t *testing.T) {
+	const harDoc = `{"log": {"version": "1.2", "entries": [
+		{
+			"request": {
+				"method": "POST",
+				"url": "https://api.example.com/token",
+				"httpVersion": "http/2.0",
+				"headers": [
+					{"name": ":authority", "value": "api.example.com"},
+					{"name": "Authorization", "value": "Bearer secret"},
+					{"name": "Content-Type", "value": "application/json"}
+				],
+				"postData": {"mimeType": "application/json", "text": "{\"grant\":\"code\"}"}
+			},
+			"response": {
+				"status": 201,
+				"statusText": "Created",
+				"httpVersion": "h2",
+				"headers": [
+					{"name": "Content-Encoding", "value": "gzip"},
+					{"name": "Content-Type", "value": "application/json"}
+				],
+				"content": {"mimeType": "application/json", "text": "{\"token\":\"t\"}"}
+			}
+		},
+		{
+			"request": {"method": "GET", "url": "https://cdn.example.com/logo.png", "headers": []},
+			"response": {"status": 200, "headers": [], "content": {"text": "iVBORw==", "encoding": "base64"}}
+		}
+	]}}`
+
+	store := NewMemoryStore()
+	imported, err := ImportHAR(strings.NewReader(harDoc), &SequentialNamingScheme{dir: "imported"},
+		WithHARHosts("api.example.com"),
+		WithHARStore(store),
+	)
+	if err != nil {
+		t.Fatalf("failed to import HAR: %v", err)
+	}
+	if imported != 1 {
+		t.Fatalf("expected 1 imported entry, got %d", imported)
+	}
+
+	req, body, err := readStoredRequest(store, "imported/0.req.http")
+	if err != nil {
+		t.Fatalf("failed to read imported request: %v", err)
+	}
+	if req.Header.Get("Authorization") != "SANITIZED" {
+		t.Errorf("expected imported request to be sanitized, got %q", req.Header.Get("Authorization"))
+	}
+	if req.Header.Get(":authority") != "" {
+		t.Errorf("expected pseudo headers not to be imported")
+	}
+	if string(body) != `{"grant":"code"}` {
+		t.Errorf("unexpected request body %q", body)
+	}
+
+	resp, respBody, err := readStoredResponse(store, "imported/0.resp.http", req)
+	if err != nil {
+		t.Fatalf("failed to read imported response: %v", err)
+	}
+	if resp.StatusCode != 201 || resp.Header.Get("Content-Encoding") != "" {
+		t.Errorf("unexpected response status %d or Content-Encoding %q", resp.StatusCode, resp.Header.Get("Content-Encoding"))
+	}
+	if string(respBody) != `{"token":"t"}` {
+		t.Errorf("unexpected response body %q", respBody)
+	}
+}
+
+func TestExportHAR_SidecarBodies(t *testing.T) {
+	const small = `{"message":"hello"}`
+	large := strings.Repeat("large ", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/small" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", "gzip")
+			_, _ = w.Write(encodeTestBody(t, GzipCoding(), small))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	store := NewMemoryStore()
+	client := TestClient(t, true,
+		WithStore(store),
+		WithNamingScheme(&SequentialNamingScheme{dir: "sidecars"}),
+		WithSidecarBodies(),
+		WithBodyStreaming(1024),
+		WithDecodedBodies(),
+	)
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/small", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	resp.Body.Close()
+	resp, err = client.Post(srv.URL+"/large", "text/plain", strings.NewReader(large))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	var buf bytes.Buffer
+	if err := ExportHAR(store, &buf); err != nil {
+		t.Fatalf("failed to export HAR: %v", err)
+	}
+	if strings.Contains(buf.String(), "X-Hypert-") {
+		t.Errorf("expected internal headers not to be exported, got:\n%s", buf.String())
+	}
+	var doc har
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to parse exported HAR: %v", err)
+	}
+	if len(doc.Log.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(doc.Log.Entries))
+	}
+	smallResp := doc.Log.Entries[0].Response
+	if smallResp.Content.Text != small || !containsHeader(smallResp.Headers, "Content-Encoding", "gzip") {
+		t.Errorf("expected decoded sidecar body with original Content-Encoding, got %+v", smallResp)
+	}
+	largeEntry := doc.Log.Entries[1]
+	if largeEntry.Request.PostData == nil || largeEntry.Request.PostData.Text != large {
+		t.Errorf("expected streamed request body to be exported")
+	}
+	if largeEntry.Response.Content.Text != large {
+		t.Errorf("expected streamed response body to be exported, got %d bytes", len(largeEntry.Response.Content.Text))
+	}
+}
+
+func containsHeader(headers []harNameValue, name, value string) bool {
+	for _, h := range headers {
+		if h.Name == name && h.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExportHAR_ErrorRecording(t *testing.T) {
+	store := NewMemoryStore()
+	if err := store.WriteRequest("0.req.http", strings.NewReader("GET https://example.com/flaky HTTP/1.1\r\nHost: example.com\r\n\r\n")); err != nil {
+		t.Fatalf("failed to write request: %v", err)
+	}
+	const errRecording = "HYPERT-ERROR/1\r\nKind: connection-reset\r\nMessage: read: connection reset by peer\r\n\r\n"
+	if err := store.WriteResponse("0.resp.http", strings.NewReader(errRecording)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := ExportHAR(store, &buf); err != nil {
+		t.Fatalf("failed to export HAR: %v", err)
+	}
+	var doc har
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to parse exported HAR: %v", err)
+	}
+	if len(doc.Log.Entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(doc.Log.Entries))
+	}
+	entry := doc.Log.Entries[0]
+	if entry.Request.URL != "https://example.com/flaky" {
+		t.Errorf("unexpected request URL %s", entry.Request.URL)
+	}
+	if entry.Response.Status != 0 || entry.Response.Error != "read: connection reset by peer" {
+		t.Errorf("expected failed response with the recorded error, got %+v", entry.Response)
+	}
+}
+
+func TestHAR_ErrorRecordingRoundTrip(t *testing.T) {
+	store := NewMemoryStore()
+	if err := store.WriteRequest("0.req.http", strings.NewReader("GET https://example.com/flaky HTTP/1.1\r\nHost: example.com\r\n\r\n")); err != nil {
+		t.Fatalf("failed to write request: %v", err)
+	}
+	const errRecording = "HYPERT-ERROR/1\r\nKind: connection-reset\r\nMessage: read: connection reset by peer\r\n\r\n"
+	if err := store.WriteResponse("0.resp.http", strings.NewReader(errRecording)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := ExportHAR(store, &buf); err != nil {
+		t.Fatalf("failed to export HAR: %v", err)
+	}
+
+	imported := NewMemoryStore()
+	if _, err := ImportHAR(&buf, &SequentialNamingScheme{dir: "imported"}, WithHARStore(imported)); err != nil {
+		t.Fatalf("failed to import HAR: %v", err)
+	}
+	req, _, err := readStoredRequest(imported, "imported/0.req.http")
+	if err != nil {
+		t.Fatalf("failed to read imported request: %v", err)
+	}
+	_, _, err = readStoredResponse(imported, "imported/0.resp.http", req) //nolint:bodyclose // response is not returned on error
+	var recordedErr *RecordedError
+	if !errors.As(err, &recordedErr) {
+		t.Fatalf("expected imported error recording, got %v", err)
+	}
+	if recordedErr.Message != "read: connection reset by peer" || !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("expected the recorded error to be kept, got %v (%s)", recordedErr, recordedErr.Kind)
+	}
+}
+
+func TestImportHAR_BrowserError(t *testing.T) {
+	const harDoc = `{"log": {"version": "1.2", "entries": [{
+		"request": {"method": "GET", "url": "https://api.example.com/down", "headers": []},
+		"response": {"status": 0, "headers": [], "content": {}, "_error": "net::ERR_CONNECTION_REFUSED"}
+	}]}}`
+
+	store := NewMemoryStore()
+	if _, err := ImportHAR(strings.NewReader(harDoc), &SequentialNamingScheme{dir: "imported"}, WithHARStore(store)); err != nil {
+		t.Fatalf("failed to import HAR: %v", err)
+	}
+	req, _, err := readStoredRequest(store, "imported/0.req.http")
+	if err != nil {
+		t.Fatalf("failed to read imported request: %v", err)
+	}
+	_, _, err = readStoredResponse(store, "imported/0.resp.http", req) //nolint:bodyclose // response is not returned on error
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("expected connection refused error, got %v", err)
+	}
+}
+
+func TestExportHAR_KeepsStoreOrder(t *testing.T) {
+	store := NewMemoryStore()
+	for _, name := range []string{"z", "a"} {
+		req := "GET https://example.com/" + name + " HTTP/1.1\r\nHost: example.com\r\n\r\n"
+		if err := store.WriteRequest(name+".req.http", strings.NewReader(req)); err != nil {
+			t.Fatalf("failed to write request: %v", err)
+		}
+		if err := store.WriteResponse(name+".resp.http", strings.NewReader("HTTP/1.1 204 No Content\r\n\r\n")); err != nil {
+			t.Fatalf("failed to write response: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := ExportHAR(store, &buf); err != nil {
+		t.Fatalf("failed to export HAR: %v", err)
+	}
+	var doc har
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to parse exported HAR: %v", err)
+	}
+	var urls []string
+	for _, entry := range doc.Log.Entries {
+		urls = append(urls, entry.Request.URL)
+	}
+	if strings.Join(urls, " ") != "https://example.com/z https://example.com/a" {
+		t.Errorf("expected entries in the order of recording, got %v", urls)
+	}
+}
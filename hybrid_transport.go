@@ -0,0 +1,45 @@
+package hypert
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// hybridTransport replays the requests, that have been already recorded and records the ones, that are missing.
+type hybridTransport struct {
+	t         T
+	scheme    NamingScheme
+	sanitizer RequestSanitizer
+	replay    *replayTransport
+	record    *recordTransport
+}
+
+func (d *hybridTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := spoolRequestBody(req, d.record.streamThreshold); err != nil {
+		return nil, fmt.Errorf("spool body: %w", err)
+	}
+	reqData, sanitizedData, err := sanitizedRequestData(d.sanitizer, req)
+	if err != nil {
+		closeSpooledBody(req.Body)
+		return nil, err
+	}
+
+	// file names are resolved the same way replay mode does it, so that the recorded files can be replayed later on.
+	reqFile, respFile := d.scheme.FileNames(sanitizedData)
+	f, err := d.replay.getStore().OpenRequest(reqFile)
+	switch {
+	case err == nil:
+		f.Close()
+		closeSpooledBody(req.Body)
+		return d.replay.replay(req, sanitizedData, reqFile, respFile)
+	case errors.Is(err, os.ErrNotExist):
+		d.t.Logf("hypert: recording %s doesn't exist, recording %s", reqFile, reqData)
+		d.replay.used.markUsed(reqFile)
+		return d.record.record(req, reqFile, respFile)
+	default:
+		closeSpooledBody(req.Body)
+		return nil, fmt.Errorf("open file %s: %w", reqFile, err)
+	}
+}
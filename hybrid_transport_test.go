@@ -0,0 +1,140 @@
+package hypert
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHybridTransport_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"0.req.http", "0.resp.http"} {
+		content, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatalf("failed to read testdata file: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	scheme, err := NewSequentialNamingScheme(dir)
+	if err != nil {
+		t.Fatalf("failed to create naming scheme: %v", err)
+	}
+	mockRT := &mockRoundTripper{
+		resp: &http.Response{ //nolint:bodyclose // Response body is closed after the round trip
+			StatusCode: http.StatusCreated,
+			Body:       io.NopCloser(bytes.NewBufferString("live response body")),
+		},
+	}
+	cfg := &config{
+		namingScheme:     scheme,
+		requestSanitizer: HeadersSanitizer("Authorization"),
+		requestValidator: noopRequestValidator{},
+		parentHTTPClient: &http.Client{Transport: mockRT},
+	}
+	transport := &hybridTransport{
+		t:         t,
+		scheme:    scheme,
+		sanitizer: cfg.requestSanitizer,
+		replay:    newReplayTransport(t, cfg, nil),
+		record:    newRecordTransport(t, cfg),
+	}
+
+	roundTrip := func(method string) (int, string) {
+		req, err := http.NewRequest(method, "https://example.com", http.NoBody)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		req.Header.Set("Authorization", "secret")
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("failed to round trip: %v", err)
+		}
+		defer resp.Body.Close()
+		if req.Header.Get("Authorization") != "secret" {
+			t.Errorf("expected original request not to be sanitized")
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read response body: %v", err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := roundTrip(http.MethodGet)
+	if status != http.StatusOK || body != "Wassup, world?" {
+		t.Errorf("expected the first request to be replayed, got %d %q", status, body)
+	}
+
+	status, body = roundTrip(http.MethodPost)
+	if status != http.StatusCreated || body != "live response body" {
+		t.Errorf("expected the second request to be made, got %d %q", status, body)
+	}
+	reqContent, err := os.ReadFile(filepath.Join(dir, "1.req.http"))
+	if err != nil {
+		t.Fatalf("expected the missing request to be recorded: %v", err)
+	}
+	if !bytes.Contains(reqContent, []byte("Authorization: SANITIZED")) {
+		t.Errorf("expected the recorded request to be sanitized, got %s", reqContent)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "1.resp.http")); err != nil {
+		t.Errorf("expected the missing response to be recorded: %v", err)
+	}
+}
+
+func TestHybridTransport_LeakGuardLeavesNoRecording(t *testing.T) {
+	const secret = "my-api-secret"
+	store := NewMemoryStore()
+	newTransport := func(t T, respSanitizer ResponseSanitizer) *hybridTransport {
+		cfg := &config{
+			namingScheme:      &staticNamingScheme{reqFile: "0.req.http", respFile: "0.resp.http"},
+			requestSanitizer:  NoOpRequestSanitizer{},
+			responseSanitizer: respSanitizer,
+			requestValidator:  noopRequestValidator{},
+			store:             store,
+			leakGuard:         &leakGuard{secrets: []string{secret}},
+			parentHTTPClient: &http.Client{Transport: &mockRoundTripper{
+				resp: newTestResponse(`{"key":"` + secret + `"}`), //nolint:bodyclose // body is closed by the transport
+			}},
+		}
+		return &hybridTransport{
+			t:         t,
+			scheme:    cfg.namingScheme,
+			sanitizer: cfg.requestSanitizer,
+			replay:    newReplayTransport(t, cfg, nil),
+			record:    newRecordTransport(t, cfg),
+		}
+	}
+	roundTrip := func(transport *hybridTransport) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, "https://example.com/", http.NoBody)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		return transport.RoundTrip(req)
+	}
+
+	mT := &mockT{T: t}
+	resp, err := roundTrip(newTransport(mT, nil)) //nolint:bodyclose // response is not returned on error
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected leak guard error")
+	}
+	if !mT.fatal {
+		t.Errorf("expected test to fail fatally")
+	}
+
+	resp, err = roundTrip(newTransport(t, ResponseJSONFieldsSanitizer("key")))
+	if err != nil {
+		t.Fatalf("expected the request to be recorded once the sanitizer is fixed, got %v", err)
+	}
+	resp.Body.Close()
+	content := readStoreContent(t, store.OpenResponse, "0.resp.http")
+	if !strings.Contains(content, `{"key":"SANITIZED"}`) {
+		t.Errorf("expected sanitized response to be recorded, got %s", content)
+	}
+}
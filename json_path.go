@@ -0,0 +1,228 @@
+package hypert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed path to the fields in JSON document, e.g. "user.email" or "items.*.token".
+// The segments are separated with dots. "*" segment matches any object key or array element,
+// and numeric segments match array elements with given index. "$." prefix and "[*]", "[0]" array notations are accepted as well.
+type jsonPath []string
+
+func parseJSONPath(p string) jsonPath {
+	p = strings.TrimPrefix(p, "$")
+	p = strings.NewReplacer("[", ".", "]", "").Replace(p)
+	var segments jsonPath
+	for _, s := range strings.Split(p, ".") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+func parseJSONPaths(paths []string) []jsonPath {
+	parsed := make([]jsonPath, 0, len(paths))
+	for _, p := range paths {
+		parsed = append(parsed, parseJSONPath(p))
+	}
+	return parsed
+}
+
+// replace calls fn on every value matching the path and sets the value to its result.
+// It returns the modified document, which should be used instead of v.
+func (p jsonPath) replace(v any, fn func(any) any) any {
+	if len(p) == 0 {
+		return fn(v)
+	}
+	segment, rest := p[0], p[1:]
+	switch node := v.(type) {
+	case map[string]any:
+		// keys are sorted, so that fn is called in a deterministic order, e.g. by Pseudonymizer
+		for _, key := range sortedKeys(node) {
+			if segment == "*" || segment == key {
+				node[key] = rest.replace(node[key], fn)
+			}
+		}
+	case []any:
+		for i, child := range node {
+			if segment == "*" || segment == strconv.Itoa(i) {
+				node[i] = rest.replace(child, fn)
+			}
+		}
+	}
+	return v
+}
+
+// decodeJSON decodes the JSON document keeping the numbers' original representation.
+// The document has to be a single JSON value, so e.g. NDJSON or a value followed by garbage is rejected.
+func decodeJSON(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if err := dec.Decode(new(any)); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after top-level JSON value")
+	}
+	return v, nil
+}
+
+// replaceJSONPaths replaces the values at given paths of JSON document with the result of fn.
+// ok is false, if the document is not a valid JSON or none of the paths matched, in which case b is returned as it is.
+func replaceJSONPaths(b []byte, paths []jsonPath, fn func(any) any) (replaced []byte, ok bool) {
+	v, err := decodeJSON(b)
+	if err != nil {
+		return b, false
+	}
+	matched := false
+	for _, p := range paths {
+		v = p.replace(v, func(v any) any {
+			matched = true
+			return fn(v)
+		})
+	}
+	if !matched {
+		return b, false
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return b, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
+
+// sanitizeJSONValue replaces any JSON value with "SANITIZED".
+func sanitizeJSONValue(_ any) any {
+	return sanitizedValue
+}
+
+// matches reports whether the path matches the segments of a value's location in the document.
+func (p jsonPath) matches(segments []string) bool {
+	if len(p) != len(segments) {
+		return false
+	}
+	for i, s := range p {
+		if s != "*" && s != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonDiff finds differences between recorded and got JSON documents.
+// Recorded "SANITIZED" values match any value, and the values at ignored paths are not compared.
+type jsonDiff struct {
+	ignored    []jsonPath
+	mismatches []Mismatch
+}
+
+func (d *jsonDiff) isIgnored(segments []string) bool {
+	for _, p := range d.ignored {
+		if p.matches(segments) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *jsonDiff) add(path, expected, got string) {
+	d.mismatches = append(d.mismatches, Mismatch{Field: "body " + path, Expected: expected, Got: got})
+}
+
+// diff compares the values found at given location, which is both displayed as path, e.g. $.users[0].name,
+// and split into segments, e.g. users, 0, name.
+func (d *jsonDiff) diff(path string, segments []string, recorded, got any) {
+	if recorded == sanitizedValue || d.isIgnored(segments) {
+		return
+	}
+	switch r := recorded.(type) {
+	case map[string]any:
+		if g, ok := got.(map[string]any); ok {
+			d.diffObjects(path, segments, r, g)
+			return
+		}
+	case []any:
+		if g, ok := got.([]any); ok {
+			d.diffArrays(path, segments, r, g)
+			return
+		}
+	case json.Number:
+		if g, ok := got.(json.Number); ok && jsonNumbersEqual(r, g) {
+			return
+		}
+	default:
+		if recorded == got {
+			return
+		}
+	}
+	d.add(path, formatJSON(recorded), formatJSON(got))
+}
+
+func (d *jsonDiff) diffObjects(path string, segments []string, recorded, got map[string]any) {
+	for _, key := range sortedKeys(recorded) {
+		childPath, childSegments := path+"."+key, appendSegment(segments, key)
+		gotValue, ok := got[key]
+		if !ok {
+			if !d.isIgnored(childSegments) {
+				d.add(childPath, formatJSON(recorded[key]), Missing)
+			}
+			continue
+		}
+		d.diff(childPath, childSegments, recorded[key], gotValue)
+	}
+	for _, key := range sortedKeys(got) {
+		if _, ok := recorded[key]; !ok && !d.isIgnored(appendSegment(segments, key)) {
+			d.add(path+"."+key, Missing, formatJSON(got[key]))
+		}
+	}
+}
+
+func (d *jsonDiff) diffArrays(path string, segments []string, recorded, got []any) {
+	for i := range recorded {
+		childPath, childSegments := fmt.Sprintf("%s[%d]", path, i), appendSegment(segments, strconv.Itoa(i))
+		if i >= len(got) {
+			if !d.isIgnored(childSegments) {
+				d.add(childPath, formatJSON(recorded[i]), Missing)
+			}
+			continue
+		}
+		d.diff(childPath, childSegments, recorded[i], got[i])
+	}
+	for i := len(recorded); i < len(got); i++ {
+		if !d.isIgnored(appendSegment(segments, strconv.Itoa(i))) {
+			d.add(fmt.Sprintf("%s[%d]", path, i), Missing, formatJSON(got[i]))
+		}
+	}
+}
+
+// appendSegment returns new slice, so that the segments of sibling values don't share the backing array.
+func appendSegment(segments []string, segment string) []string {
+	return append(append(make([]string, 0, len(segments)+1), segments...), segment)
+}
+
+func jsonNumbersEqual(a, b json.Number) bool {
+	if a == b {
+		return true
+	}
+	af, errA := a.Float64()
+	bf, errB := b.Float64()
+	return errA == nil && errB == nil && af == bf
+}
+
+func formatJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,145 @@
+package hypert
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// jwtRegexp matches compact serialized JWTs. Both header and payload are JSON objects, so they start with "eyJ" once encoded.
+var jwtRegexp = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*`)
+
+// jwtSignature is the dummy signature of the sanitized tokens. It's valid base64url, so that the libraries decoding the signature accept it.
+var jwtSignature = base64.RawURLEncoding.EncodeToString([]byte(sanitizedValue))
+
+type jwtConfig struct {
+	keptClaims map[string]struct{}
+}
+
+// JWTOption can be used to customize JWTSanitizer and JWTResponseSanitizer behaviour.
+type JWTOption func(*jwtConfig)
+
+// WithJWTKeptClaims sets the claims, that are kept as they are in the sanitized tokens. All the other claims are sanitized:
+// strings are set to "SANITIZED", numbers to 0 and booleans to false, so that the claims keep their types.
+// By default, the registered claims, that are not secret, are kept: iss, sub, aud, exp, nbf, iat and scope.
+func WithJWTKeptClaims(claims ...string) JWTOption {
+	return func(cfg *jwtConfig) {
+		cfg.keptClaims = make(map[string]struct{}, len(claims))
+		for _, c := range claims {
+			cfg.keptClaims[c] = struct{}{}
+		}
+	}
+}
+
+func newJWTConfig(opts []JWTOption) *jwtConfig {
+	cfg := &jwtConfig{}
+	WithJWTKeptClaims("iss", "sub", "aud", "exp", "nbf", "iat", "scope")(cfg)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// JWTSanitizer finds JWTs in the stored request's URL (e.g. query params), headers and body (e.g. JSON fields),
+// and replaces them with tokens, that have the same header, the kept claims, the other claims set to "SANITIZED"
+// and a dummy signature. The tokens stay structurally valid, so that the code decoding them, e.g. to read "exp" claim, still works.
+// Lookup WithJWTKeptClaims to adjust the kept claims.
+//
+// The signature of the sanitized tokens is not valid, so they can't be verified.
+// HeadersSanitizer replaces the whole header value regardless of the order of the sanitizers, so the header carrying the token,
+// e.g. Authorization, should be left to JWTSanitizer:
+//
+//	hypert.ComposedRequestSanitizer(
+//		hypert.DefaultHeadersSanitizerExcept("Authorization"),
+//		hypert.DefaultQueryParamsSanitizer(),
+//		hypert.JWTSanitizer(),
+//	)
+func JWTSanitizer(opts ...JWTOption) RequestSanitizer {
+	cfg := newJWTConfig(opts)
+	return redactingSanitizer(cfg.sanitizeTokens)
+}
+
+// JWTResponseSanitizer works like JWTSanitizer, but for the stored response's headers and body.
+func JWTResponseSanitizer(opts ...JWTOption) ResponseSanitizer {
+	cfg := newJWTConfig(opts)
+	return ComposedResponseSanitizer(
+		ResponseSanitizerFunc(func(resp *http.Response) *http.Response {
+			for _, values := range resp.Header {
+				for i, v := range values {
+					values[i] = cfg.sanitizeTokens(v)
+				}
+			}
+			return resp
+		}),
+		responseBodySanitizer(func(body []byte) []byte {
+			return []byte(cfg.sanitizeTokens(string(body)))
+		}),
+	)
+}
+
+func (cfg *jwtConfig) sanitizeTokens(s string) string {
+	return jwtRegexp.ReplaceAllStringFunc(s, cfg.sanitizeToken)
+}
+
+// sanitizeToken returns the sanitized token, or the original value, if it's not a JWT.
+func (cfg *jwtConfig) sanitizeToken(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return token
+	}
+	var header map[string]any
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return token
+	}
+	var claims map[string]any
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return token
+	}
+	for name, value := range claims {
+		if _, ok := cfg.keptClaims[name]; !ok {
+			claims[name] = sanitizeClaim(value)
+		}
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return token
+	}
+	return parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + jwtSignature
+}
+
+// sanitizeClaim returns the sanitized value of the same type as the claim, so that the code decoding the claims into typed fields still works.
+func sanitizeClaim(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		return json.Number("0")
+	case bool:
+		return false
+	case map[string]any:
+		for name, value := range v {
+			v[name] = sanitizeClaim(value)
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = sanitizeClaim(value)
+		}
+		return v
+	case nil:
+		return nil
+	default:
+		return sanitizedValue
+	}
+}
+
+func decodeJWTSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
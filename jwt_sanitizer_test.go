@@ -0,0 +1,136 @@
+package hypert
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newTestJWT(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to marshal claims: %v", err)
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	return header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".SflKxwRJSMeKKF2QT4fwpMeJf36POk6yJV_adQssw5c"
+}
+
+func decodeTestJWTClaims(t *testing.T, token string) map[string]any {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[2] != jwtSignature {
+		t.Fatalf("expected sanitized JWT, got %s", token)
+	}
+	for i, part := range parts {
+		if _, err := base64.RawURLEncoding.DecodeString(part); err != nil {
+			t.Fatalf("expected segment %d of sanitized JWT to be valid base64url: %v", i, err)
+		}
+	}
+	var claims map[string]any
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		t.Fatalf("failed to decode claims: %v", err)
+	}
+	return claims
+}
+
+func TestJWTSanitizer(t *testing.T) {
+	token := newTestJWT(t, map[string]any{"sub": "user-1", "exp": 1893456000, "email": "a@example.com", "auth_time": 1700000000, "admin": true})
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/callback?id_token="+token,
+		strings.NewReader(`{"refresh":"`+token+`","other":"value"}`))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	sanitizedReq := JWTSanitizer().SanitizeRequest(req)
+
+	auth := sanitizedReq.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		t.Fatalf("expected Bearer prefix to be kept, got %s", auth)
+	}
+	claims := decodeTestJWTClaims(t, strings.TrimPrefix(auth, "Bearer "))
+	if claims["sub"] != "user-1" || claims["exp"] != json.Number("1893456000") || claims["email"] != "SANITIZED" {
+		t.Errorf("unexpected claims: %v", claims)
+	}
+	if claims["auth_time"] != json.Number("0") || claims["admin"] != false {
+		t.Errorf("expected sanitized numeric and boolean claims to keep their types, got %v", claims)
+	}
+	decodeTestJWTClaims(t, sanitizedReq.URL.Query().Get("id_token"))
+
+	body, err := io.ReadAll(sanitizedReq.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	var parsed map[string]string
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		t.Fatalf("expected body to stay valid JSON: %v", err)
+	}
+	decodeTestJWTClaims(t, parsed["refresh"])
+	if parsed["other"] != "value" {
+		t.Errorf("expected other fields to be kept, got %v", parsed)
+	}
+}
+
+func TestJWTResponseSanitizer(t *testing.T) {
+	token := newTestJWT(t, map[string]any{"sub": "user-1", "tenant": "acme"})
+	resp := newTestResponse(`{"access_token":"` + token + `"}`)
+	resp.Header.Set("X-Token", token)
+
+	sanitized := JWTResponseSanitizer(WithJWTKeptClaims("tenant")).SanitizeResponse(resp)
+	defer sanitized.Body.Close()
+
+	claims := decodeTestJWTClaims(t, sanitized.Header.Get("X-Token"))
+	if claims["sub"] != "SANITIZED" || claims["tenant"] != "acme" {
+		t.Errorf("unexpected claims: %v", claims)
+	}
+	body, err := io.ReadAll(sanitized.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	var parsed map[string]string
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		t.Fatalf("expected body to stay valid JSON: %v", err)
+	}
+	decodeTestJWTClaims(t, parsed["access_token"])
+}
+
+func TestJWTSanitizer_DefaultHeadersSanitizerExcept(t *testing.T) {
+	token := newTestJWT(t, map[string]any{"sub": "user-1"})
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Cookie", "session=abc")
+
+	sanitizedReq := ComposedRequestSanitizer(
+		DefaultHeadersSanitizerExcept("Authorization"),
+		DefaultQueryParamsSanitizer(),
+		JWTSanitizer(),
+	).SanitizeRequest(req)
+
+	claims := decodeTestJWTClaims(t, strings.TrimPrefix(sanitizedReq.Header.Get("Authorization"), "Bearer "))
+	if claims["sub"] != "user-1" {
+		t.Errorf("expected token claims to be kept, got %v", claims)
+	}
+	if got := sanitizedReq.Header.Get("Cookie"); got != "SANITIZED" {
+		t.Errorf("expected other default headers to be sanitized, got Cookie %s", got)
+	}
+}
+
+func TestJWTSanitizer_NotJWT(t *testing.T) {
+	const value = "eyJnot.eyJa.jwt"
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/?v="+url.QueryEscape(value), http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	sanitizedReq := JWTSanitizer().SanitizeRequest(req)
+	if got := sanitizedReq.URL.Query().Get("v"); got != value {
+		t.Errorf("expected value to be kept, got %s", got)
+	}
+}
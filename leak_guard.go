@@ -0,0 +1,124 @@
+package hypert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// LeakPattern describes a kind of secret, that should never be stored in the recordings.
+type LeakPattern struct {
+	Name   string
+	Regexp *regexp.Regexp
+}
+
+// DefaultLeakPatterns returns the patterns of common tokens, that are checked by the leak guard enabled with WithLeakGuard option.
+// The patterns don't match "SANITIZED" placeholders left by the sanitizers.
+func DefaultLeakPatterns() []LeakPattern {
+	return []LeakPattern{
+		{Name: "JWT", Regexp: regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]{16,}`)},
+		{Name: "AWS access key ID", Regexp: regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{Name: "bearer token", Regexp: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]{16,}=*`)},
+		{Name: "private key", Regexp: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	}
+}
+
+// WithLeakGuard enables the check of the stored requests and responses in record and hybrid modes.
+// If the sanitized request or response contains any of the secrets, or matches any of DefaultLeakPatterns,
+// the test fails with T.Fatalf before the recording is written, so that it's not committed by accident.
+// The secrets are usually read from environment variables. Empty values are ignored.
+//
+// Encoded (e.g. gzipped) bodies are not checked, unless they are stored decoded with WithDecodedBodies option.
+// The bodies streamed with WithBodyStreaming option are checked in chunks, without reading them into memory.
+func WithLeakGuard(secrets ...string) Option {
+	return func(cfg *config) {
+		if cfg.leakGuard == nil {
+			cfg.leakGuard = &leakGuard{patterns: DefaultLeakPatterns()}
+		}
+		for _, s := range secrets {
+			if s != "" {
+				cfg.leakGuard.secrets = append(cfg.leakGuard.secrets, s)
+			}
+		}
+	}
+}
+
+// WithLeakPatterns enables the leak guard just like WithLeakGuard, checking the recordings against additional patterns.
+func WithLeakPatterns(patterns ...LeakPattern) Option {
+	return func(cfg *config) {
+		WithLeakGuard()(cfg)
+		cfg.leakGuard.patterns = append(cfg.leakGuard.patterns, patterns...)
+	}
+}
+
+type leakGuard struct {
+	secrets  []string
+	patterns []LeakPattern
+}
+
+// check returns an error describing the first leak found in the dump. The leaked value itself is not a part of the message.
+// It is safe to call it on nil leakGuard.
+func (g *leakGuard) check(dump []byte) error {
+	return g.checkAt(dump, 0)
+}
+
+// checkAt works like check, but the reported positions are offset by the position of the dump in the whole content.
+func (g *leakGuard) checkAt(dump []byte, offset int64) error {
+	if g == nil {
+		return nil
+	}
+	for i, s := range g.secrets {
+		if idx := bytes.Index(dump, []byte(s)); idx >= 0 {
+			return fmt.Errorf("secret #%d found at byte %d", i+1, offset+int64(idx))
+		}
+	}
+	for _, p := range g.patterns {
+		if loc := p.Regexp.FindIndex(dump); loc != nil {
+			return fmt.Errorf("%s found at byte %d", p.Name, offset+int64(loc[0]))
+		}
+	}
+	return nil
+}
+
+const (
+	leakCheckChunkSize = 64 << 10
+	// leakCheckOverlap is the size of the chunk's tail, that is checked again with the following chunk,
+	// so that the leaks spanning the chunks' boundary are found. It's extended to the length of the longest secret.
+	leakCheckOverlap = 4 << 10
+)
+
+// checkReader works like check, but reads the content in chunks, so that e.g. the streamed bodies are checked without
+// reading them into memory. The chunks overlap, so that the leaks spanning their boundaries are found as well.
+func (g *leakGuard) checkReader(r io.Reader) error {
+	if g == nil {
+		return nil
+	}
+	overlap := leakCheckOverlap
+	for _, s := range g.secrets {
+		if len(s) > overlap {
+			overlap = len(s)
+		}
+	}
+	chunk := make([]byte, leakCheckChunkSize)
+	buf := make([]byte, 0, overlap+leakCheckChunkSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(r, chunk)
+		buf = append(buf, chunk[:n]...)
+		if leakErr := g.checkAt(buf, offset); leakErr != nil {
+			return leakErr
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+		if drop := len(buf) - overlap; drop > 0 {
+			offset += int64(drop)
+			buf = append(buf[:0], buf[drop:]...)
+		}
+	}
+}
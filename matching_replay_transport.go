@@ -0,0 +1,179 @@
+package hypert
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// WithMatchingReplay makes the replay independent of the order of requests.
+// Instead of asking the naming scheme for the file names of each request, all the recordings listed by the Store
+// are loaded up front, and each request is served with the recording, for which the configured RequestValidator reports no mismatches.
+// Each recording is used at most once, so the same request made twice needs to be recorded twice.
+// If several recordings match, e.g. POST requests to the same URL, and the validator doesn't compare the bodies,
+// the one with the same body is preferred, and the first one in the order of recording otherwise.
+//
+// It's useful for the code, that makes requests concurrently, so their order differs between test runs.
+// The store needs to support listing recordings. The option only affects replay mode.
+func WithMatchingReplay() Option {
+	return func(cfg *config) {
+		cfg.matchingReplay = true
+	}
+}
+
+// maxNearestCandidates is the number of recordings, that are listed when no recording matches the request.
+const maxNearestCandidates = 3
+
+type replayCandidate struct {
+	reqFile  string
+	respFile string
+	data     RequestData
+	consumed bool
+}
+
+type matchingReplayTransport struct {
+	replay *replayTransport
+
+	loadOnce   sync.Once
+	loadErr    error
+	mu         sync.Mutex
+	candidates []*replayCandidate
+}
+
+func (d *matchingReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	d.loadOnce.Do(func() {
+		d.loadErr = d.loadCandidates()
+	})
+	if d.loadErr != nil {
+		d.replay.t.Fatalf("load recordings: %v", d.loadErr)
+		return nil, d.loadErr
+	}
+
+	if err := spoolRequestBody(req, d.replay.streamThreshold); err != nil {
+		return nil, fmt.Errorf("spool body: %w", err)
+	}
+	sanitizedReq := d.replay.sanitizer.SanitizeRequest(req)
+	requestData, err := requestDataFromRequest(sanitizedReq)
+	closeSpooledBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	candidate, err := d.match(requestData)
+	if err != nil {
+		d.replay.t.Fatalf("%v", err)
+		return nil, err
+	}
+	d.replay.used.markUsed(candidate.reqFile)
+	return d.replay.respond(req, candidate.respFile)
+}
+
+func (d *matchingReplayTransport) loadCandidates() error {
+	recordings, err := d.replay.getStore().List()
+	if err != nil {
+		return fmt.Errorf("list recordings: %w", err)
+	}
+	for _, r := range recordings {
+		data, err := d.replay.readReqFromFile(r.Request)
+		if err != nil {
+			return err
+		}
+		d.candidates = append(d.candidates, &replayCandidate{
+			reqFile:  r.Request,
+			respFile: r.Response,
+			data:     data,
+		})
+	}
+	return nil
+}
+
+type scoredCandidate struct {
+	candidate  *replayCandidate
+	mismatches []string
+}
+
+// match finds the not consumed recording, that matches the request and marks it as consumed.
+// Out of the matching recordings, the first one with the same body is chosen, or the first one, if none has it.
+func (d *matchingReplayTransport) match(got RequestData) (*replayCandidate, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var matched *replayCandidate
+	var scored []scoredCandidate
+	for _, candidate := range d.candidates {
+		if candidate.consumed {
+			continue
+		}
+		mismatches := d.mismatches(d.replay.validator, candidate.data, got)
+		if len(mismatches) > 0 {
+			scored = append(scored, scoredCandidate{candidate: candidate, mismatches: mismatches})
+			continue
+		}
+		if len(d.mismatches(BodyValidator(), candidate.data, got)) == 0 {
+			matched = candidate
+			break
+		}
+		if matched == nil {
+			matched = candidate
+		}
+	}
+	if matched != nil {
+		matched.consumed = true
+		return matched, nil
+	}
+	if len(scored) == 0 {
+		return nil, fmt.Errorf("no matching recording for %s: all %d recordings have been already used - %s",
+			got, len(d.candidates), helpMsgReplayFileDoesntExist)
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return len(scored[i].mismatches) < len(scored[j].mismatches)
+	})
+	if len(scored) > maxNearestCandidates {
+		scored = scored[:maxNearestCandidates]
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "no matching recording for %s. Nearest candidates:", got)
+	for _, s := range scored {
+		fmt.Fprintf(&sb, "\n\t%s (%s):", s.candidate.reqFile, s.candidate.data)
+		for _, m := range s.mismatches {
+			fmt.Fprintf(&sb, "\n\t\t%s", m)
+		}
+	}
+	return nil, fmt.Errorf("%s", sb.String())
+}
+
+// mismatches runs the validator against the recording, collecting everything it reports instead of failing the test.
+func (d *matchingReplayTransport) mismatches(validator RequestValidator, recorded, got RequestData) []string {
+	mT := &collectingT{name: d.replay.t.Name()}
+	err := validator.Validate(mT, recorded.clone(), got.clone())
+	if mismatches, ok := asValidationError(err); ok {
+		for _, m := range mismatches {
+			mT.messages = append(mT.messages, m.String())
+		}
+	} else if err != nil {
+		mT.messages = append(mT.messages, err.Error())
+	}
+	return mT.messages
+}
+
+// collectingT is T implementation, that collects the failure messages instead of failing the test.
+type collectingT struct {
+	name     string
+	messages []string
+}
+
+func (c *collectingT) Helper()                 {}
+func (c *collectingT) Name() string            { return c.name }
+func (c *collectingT) Log(_ ...any)            {}
+func (c *collectingT) Logf(_ string, _ ...any) {}
+func (c *collectingT) Error(args ...any)       { c.messages = append(c.messages, fmt.Sprint(args...)) }
+func (c *collectingT) Errorf(format string, args ...any) {
+	c.messages = append(c.messages, fmt.Sprintf(format, args...))
+}
+func (c *collectingT) Fatal(args ...any) { c.messages = append(c.messages, fmt.Sprint(args...)) }
+func (c *collectingT) Fatalf(format string, args ...any) {
+	c.messages = append(c.messages, fmt.Sprintf(format, args...))
+}
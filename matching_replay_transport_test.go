@@ -0,0 +1,158 @@
+package hypert
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func writeRecording(t *testing.T, dir, name, reqPath, respBody string) {
+	t.Helper()
+	reqContent := "GET https://example.com" + reqPath + " HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	respContent := "HTTP/1.1 200 OK\r\nContent-Length: " + strconv.Itoa(len(respBody)) + "\r\n\r\n" + respBody
+	if err := os.WriteFile(filepath.Join(dir, name+".req.http"), []byte(reqContent), 0o600); err != nil {
+		t.Fatalf("failed to write request file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".resp.http"), []byte(respContent), 0o600); err != nil {
+		t.Fatalf("failed to write response file: %v", err)
+	}
+}
+
+func TestMatchingReplayTransport_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	writeRecording(t, dir, "0", "/a", "response a")
+	writeRecording(t, dir, "1", "/b", "response b")
+	scheme, err := NewSequentialNamingScheme(dir)
+	if err != nil {
+		t.Fatalf("failed to create naming scheme: %v", err)
+	}
+
+	mT := &mockT{T: t}
+	cfg := &config{
+		namingScheme:     scheme,
+		requestSanitizer: NoOpRequestSanitizer{},
+		requestValidator: DefaultRequestValidator(),
+		store:            NewFileStore(dir),
+	}
+	transport := newMatchingReplayTransport(mT, cfg, nil)
+
+	roundTrip := func(path string) (string, error) {
+		req, err := http.NewRequest(http.MethodGet, "https://example.com"+path, http.NoBody)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read response body: %v", err)
+		}
+		return string(body), nil
+	}
+
+	for _, path := range []string{"/b", "/a"} {
+		body, err := roundTrip(path)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", path, err)
+		}
+		if body != "response "+path[1:] {
+			t.Errorf("expected response for %s, got %q", path, body)
+		}
+	}
+	if mT.failed {
+		t.Fatalf("unexpected failure: %s", mT.msg)
+	}
+
+	t.Run("recording is used at most once", func(t *testing.T) {
+		if _, err := roundTrip("/a"); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		if !mT.fatal || !strings.Contains(mT.msg, "already used") {
+			t.Errorf("expected fatal error about used recordings, got %q", mT.msg)
+		}
+	})
+}
+
+func TestMatchingReplayTransport_NearestCandidates(t *testing.T) {
+	dir := t.TempDir()
+	writeRecording(t, dir, "0", "/a", "response a")
+	scheme, err := NewSequentialNamingScheme(dir)
+	if err != nil {
+		t.Fatalf("failed to create naming scheme: %v", err)
+	}
+	mT := &mockT{T: t}
+	cfg := &config{
+		namingScheme:     scheme,
+		requestSanitizer: NoOpRequestSanitizer{},
+		requestValidator: DefaultRequestValidator(),
+		store:            NewFileStore(dir),
+	}
+	transport := newMatchingReplayTransport(mT, cfg, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/c", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(mT.msg, "no matching recording") ||
+		!strings.Contains(mT.msg, filepath.Join(dir, "0.req.http")) ||
+		!strings.Contains(mT.msg, "path: expected '/a', got '/c'") {
+		t.Errorf("expected error listing the nearest candidate, got %q", mT.msg)
+	}
+}
+
+func TestMatchingReplayTransport_SameURLDifferentBodies(t *testing.T) {
+	dir := t.TempDir()
+	for i, body := range []string{`{"name":"a"}`, `{"name":"b"}`} {
+		reqContent := "POST https://example.com/items HTTP/1.1\r\nHost: example.com\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+		respContent := "HTTP/1.1 201 Created\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+		name := filepath.Join(dir, strconv.Itoa(i))
+		if err := os.WriteFile(name+".req.http", []byte(reqContent), 0o600); err != nil {
+			t.Fatalf("failed to write request file: %v", err)
+		}
+		if err := os.WriteFile(name+".resp.http", []byte(respContent), 0o600); err != nil {
+			t.Fatalf("failed to write response file: %v", err)
+		}
+	}
+	scheme, err := NewSequentialNamingScheme(dir)
+	if err != nil {
+		t.Fatalf("failed to create naming scheme: %v", err)
+	}
+	cfg := &config{
+		namingScheme:     scheme,
+		requestSanitizer: NoOpRequestSanitizer{},
+		requestValidator: DefaultRequestValidator(),
+		store:            NewFileStore(dir),
+	}
+	transport := newMatchingReplayTransport(t, cfg, nil)
+
+	for _, body := range []string{`{"name":"b"}`, `{"name":"a"}`} {
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/items", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", body, err)
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to read response body: %v", err)
+		}
+		if string(respBody) != body {
+			t.Errorf("expected the recording with body %s to be replayed, got %s", body, respBody)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package hypert
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	modeEnvVar = "HYPERT_MODE"
+	runEnvVar  = "HYPERT_RUN"
+)
+
+// modeFlag and runFlag are -hypert.mode and -hypert.run test flags. They are registered only in test binaries, see isTestBinary,
+// so that the programs importing hypert, e.g. cmd/hypert, don't get them.
+// The flags are defined only in the test binaries of the packages, that import hypert, so "go test ./... -hypert.mode=record" fails
+// in the other packages. HYPERT_MODE and HYPERT_RUN environment variables should be used, when testing multiple packages.
+var modeFlag, runFlag = registerTestFlags()
+
+func registerTestFlags() (mode, run *string) {
+	mode, run = new(string), new(string)
+	if !isTestBinary(os.Args) {
+		return mode, run
+	}
+	flag.StringVar(mode, "hypert.mode", "", "override hypert.TestClient mode: record, replay, hybrid or passthrough")
+	flag.StringVar(run, "hypert.run", "", "limit -hypert.mode or "+modeEnvVar+" override to the tests with names matching the regular expression")
+	return mode, run
+}
+
+// isTestBinary tells, whether the program is a test binary built by go test, judging by its name or testing package's flags.
+// testing.Testing can't be used, as it's not available in the oldest supported Go version,
+// and the flags have to be registered before the test binary parses them.
+func isTestBinary(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	if strings.HasSuffix(strings.TrimSuffix(filepath.Base(args[0]), ".exe"), ".test") {
+		return true
+	}
+	for _, arg := range args[1:] {
+		if strings.HasPrefix(arg, "-test.") || strings.HasPrefix(arg, "--test.") {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMode parses the mode name, as accepted by HYPERT_MODE environment variable and -hypert.mode test flag.
+func ParseMode(s string) (Mode, error) {
+	for _, m := range []Mode{ModeReplay, ModeRecord, ModeHybrid, ModePassthrough} {
+		if strings.EqualFold(s, m.String()) {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown mode '%s', expected one of record, replay, hybrid, passthrough", s)
+}
+
+// modeOverride is the mode override that can be set outside the test code.
+type modeOverride struct {
+	mode   string
+	run    string
+	source string
+}
+
+func modeOverrideFromFlagsAndEnv() modeOverride {
+	o := modeOverride{
+		run: os.Getenv(runEnvVar),
+	}
+	if *runFlag != "" {
+		o.run = *runFlag
+	}
+	switch {
+	case *modeFlag != "":
+		o.mode, o.source = *modeFlag, "-hypert.mode flag"
+	case os.Getenv(modeEnvVar) != "":
+		o.mode, o.source = os.Getenv(modeEnvVar), modeEnvVar+" environment variable"
+	}
+	return o
+}
+
+// resolveMode returns the effective mode for given test, taking the override into consideration.
+func resolveMode(t T, configured Mode, override modeOverride) Mode {
+	t.Helper()
+	mode, source := configured, "TestClient arguments"
+	if override.mode != "" && overrideAppliesTo(t, override.run) {
+		overridden, err := ParseMode(override.mode)
+		if err != nil {
+			t.Fatalf("hypert: invalid mode in %s: %s", override.source, err.Error())
+			return configured
+		}
+		mode, source = overridden, override.source
+	}
+	t.Logf("hypert: effective mode is %s (set by %s)", mode, source)
+	return mode
+}
+
+func overrideAppliesTo(t T, run string) bool {
+	t.Helper()
+	if run == "" {
+		return true
+	}
+	re, err := regexp.Compile(run)
+	if err != nil {
+		t.Fatalf("hypert: invalid test name regular expression '%s': %s", run, err.Error())
+		return false
+	}
+	return re.MatchString(t.Name())
+}
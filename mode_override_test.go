@@ -0,0 +1,110 @@
+package hypert
+
+import (
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	for _, mode := range []Mode{ModeReplay, ModeRecord, ModeHybrid, ModePassthrough} {
+		got, err := ParseMode(mode.String())
+		if err != nil {
+			t.Errorf("unexpected error parsing %s: %v", mode, err)
+		}
+		if got != mode {
+			t.Errorf("expected %s, got %s", mode, got)
+		}
+	}
+	if _, err := ParseMode("rec"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestResolveMode(t *testing.T) {
+	testCases := []struct {
+		name       string
+		configured Mode
+		override   modeOverride
+		expected   Mode
+		expectFail bool
+	}{
+		{
+			name:       "no override",
+			configured: ModeRecord,
+			expected:   ModeRecord,
+		},
+		{
+			name:       "override without test name filter",
+			configured: ModeRecord,
+			override:   modeOverride{mode: "replay"},
+			expected:   ModeReplay,
+		},
+		{
+			name:       "override with matching test name filter",
+			configured: ModeReplay,
+			override:   modeOverride{mode: "hybrid", run: "^TestResolveMode/"},
+			expected:   ModeHybrid,
+		},
+		{
+			name:       "override with not matching test name filter",
+			configured: ModeReplay,
+			override:   modeOverride{mode: "record", run: "^TestOther$"},
+			expected:   ModeReplay,
+		},
+		{
+			name:       "invalid mode",
+			configured: ModeReplay,
+			override:   modeOverride{mode: "invalid"},
+			expected:   ModeReplay,
+			expectFail: true,
+		},
+		{
+			name:       "invalid test name filter",
+			configured: ModeReplay,
+			override:   modeOverride{mode: "record", run: "("},
+			expected:   ModeReplay,
+			expectFail: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mT := &mockT{T: t}
+			got := resolveMode(mT, tc.configured, tc.override)
+			if got != tc.expected {
+				t.Errorf("expected mode %s, got %s", tc.expected, got)
+			}
+			if mT.failed != tc.expectFail {
+				t.Errorf("expected fail to be %v, got %v", tc.expectFail, mT.failed)
+			}
+		})
+	}
+}
+
+func TestConfigWithDefaults_ModeFromEnv(t *testing.T) {
+	t.Setenv(modeEnvVar, "hybrid")
+	t.Setenv(runEnvVar, "")
+	cfg := configWithDefaults(t, true, nil)
+	if cfg.mode != ModeHybrid {
+		t.Errorf("expected mode to be overridden to %s, got %s", ModeHybrid, cfg.mode)
+	}
+}
+
+func TestIsTestBinary(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		expected bool
+	}{
+		{name: "go test binary", args: []string{"/tmp/go-build123/b001/hypert.test", "-test.v=true"}, expected: true},
+		{name: "windows test binary", args: []string{`C:\Temp\hypert.test.exe`}, expected: true},
+		{name: "renamed test binary", args: []string{"./api-tests", "-test.run=TestMyAPI"}, expected: true},
+		{name: "program", args: []string{"/usr/local/bin/hypert", "export", "-o", "out.har"}, expected: false},
+		{name: "no args", args: nil, expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isTestBinary(tc.args); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
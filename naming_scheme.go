@@ -29,6 +29,14 @@ type NamingScheme interface {
 	FileNames(RequestData) (reqFile, respFile string)
 }
 
+// DirNamingScheme is a NamingScheme, that places all the files in a single directory.
+// It allows hypert to look up all the recordings of the test, e.g. to find the ones, that were not used.
+type DirNamingScheme interface {
+	NamingScheme
+	// Dir returns the directory, in which the files are placed.
+	Dir() string
+}
+
 // SequentialNamingScheme should be initialized using NewSequentialNamingScheme function.
 // It names the files following (<dir>/0.req.http, <dir>/1.resp.http), (<dir>/1.req.http, <dir>/1.resp.http) convention.
 type SequentialNamingScheme struct {
@@ -54,6 +62,11 @@ func NewSequentialNamingScheme(dir string) (*SequentialNamingScheme, error) {
 	}, nil
 }
 
+// Dir returns the directory, in which the files are placed.
+func (s *SequentialNamingScheme) Dir() string {
+	return s.dir
+}
+
 func (s *SequentialNamingScheme) FileNames(_ RequestData) (reqFile, respFile string) {
 	s.requestIndexMx.Lock()
 	requestIndex := strconv.Itoa(s.requestIndex)
@@ -76,6 +89,11 @@ type PathBasedNamingScheme struct {
 	counter map[string]int
 }
 
+// Dir returns the directory, in which the files are placed.
+func (s *PathBasedNamingScheme) Dir() string {
+	return s.dir
+}
+
 // FileNames returns filenames based on the request path
 func (s *PathBasedNamingScheme) FileNames(data RequestData) (reqFile, respFile string) {
 	s.mu.Lock()
@@ -147,10 +165,15 @@ func normalizeMultipartBody(bodyBytes []byte, contentType string) []byte {
 	}
 
 	// Replace all occurrences of the boundary with a normalized value
-	normalizedBoundary := []byte("NORMALIZED_BOUNDARY")
-	oldBoundary := []byte(boundary)
+	return bytes.ReplaceAll(bodyBytes, []byte(boundary), []byte(normalizedBoundary))
+}
 
-	return bytes.ReplaceAll(bodyBytes, oldBoundary, normalizedBoundary)
+// normalizedBoundary replaces random multipart boundaries, so that the bodies are stable across requests.
+const normalizedBoundary = "NORMALIZED_BOUNDARY"
+
+// Dir returns the directory, in which the files are placed.
+func (s *ContentHashNamingScheme) Dir() string {
+	return s.dir
 }
 
 // FileNames returns filenames based on the request path and content hash
@@ -166,6 +189,10 @@ func (s *ContentHashNamingScheme) FileNames(data RequestData) (reqFile, respFile
 
 	// Create a hash of path and content
 	content := data.BodyBytes
+	if data.isStreamed() {
+		// streamed bodies are not kept in memory, so their digest is hashed instead
+		content = []byte(data.BodySHA256)
+	}
 	if content == nil {
 		content = []byte{}
 	}
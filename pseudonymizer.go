@@ -0,0 +1,229 @@
+package hypert
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Pseudonymizer replaces sensitive values with placeholders, that are consistent across all the requests and responses of a test.
+// Each distinct value is mapped to the next "SANITIZED-<n>" placeholder, so e.g. the user ID returned in one response
+// and sent in the following request is replaced with the same placeholder, and the relationship between them is kept.
+// JSON numbers are replaced with numeric placeholders, e.g. 1000000001 for SANITIZED-1, so that the sanitized bodies still decode into typed structs.
+//
+// In replay mode, the placeholders read from the recorded responses are sent back by the client, and are kept as they are,
+// so that the sanitized requests match the recorded ones.
+// Placeholders are assigned in order of appearance, so the test should make its calls in a deterministic order.
+//
+// Pseudonymizer should be initialized using NewPseudonymizer function, and shared by request and response sanitizers of a single TestClient, e.g.
+//
+//	p := hypert.NewPseudonymizer()
+//	client := hypert.TestClient(t, false,
+//		hypert.WithRequestSanitizer(hypert.ComposedRequestSanitizer(hypert.DefaultRequestSanitizer(), p.JSONBodySanitizer("user_id"))),
+//		hypert.WithResponseSanitizer(hypert.ComposedResponseSanitizer(hypert.DefaultResponseSanitizer(), p.ResponseJSONFieldsSanitizer("id"))),
+//	)
+type Pseudonymizer struct {
+	mu sync.Mutex
+	// pseudonyms maps the values to the numbers of their placeholders.
+	pseudonyms map[string]int
+	// numeric holds the numeric placeholders issued for JSON numbers or read from the replayed responses,
+	// mapped to the numbers of their placeholders.
+	numeric map[string]int
+	next    int
+}
+
+// NewPseudonymizer creates a new Pseudonymizer.
+func NewPseudonymizer() *Pseudonymizer {
+	return &Pseudonymizer{
+		pseudonyms: make(map[string]int),
+		numeric:    make(map[string]int),
+		next:       1,
+	}
+}
+
+var pseudonymRegexp = regexp.MustCompile(`^` + sanitizedValue + `-(\d+)$`)
+
+// numericPseudonymBase is added to the placeholder's number to get the numeric placeholder used in JSON,
+// e.g. 1000000001 for SANITIZED-1, so that the numeric values are still decoded into the client's typed structs.
+const numericPseudonymBase = 1000000000
+
+// Pseudonym returns the placeholder for given value. Empty values and placeholders are returned as they are.
+// The digits of numeric placeholders, that were issued before, e.g. 1000000001, are mapped to the corresponding placeholder, e.g. SANITIZED-1.
+func (p *Pseudonymizer) Pseudonym(value string) string {
+	if value == "" || value == sanitizedValue {
+		return value
+	}
+	if pseudonymRegexp.MatchString(value) {
+		p.number(value)
+		return value
+	}
+	return fmt.Sprintf("%s-%d", sanitizedValue, p.number(value))
+}
+
+// number returns the number of the placeholder for given value.
+func (p *Pseudonymizer) number(value string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// placeholders come back from the replayed responses; the following values should get the same numbers as during recording
+	if m := pseudonymRegexp.FindStringSubmatch(value); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			p.observe(n)
+			return n
+		}
+	}
+	if n, ok := p.numeric[value]; ok {
+		return n
+	}
+	if n, ok := p.pseudonyms[value]; ok {
+		return n
+	}
+	n := p.next
+	p.next++
+	p.pseudonyms[value] = n
+	return n
+}
+
+// numericPseudonym returns the numeric placeholder for JSON number, and remembers it as issued.
+func (p *Pseudonymizer) numericPseudonym(value string) json.Number {
+	n := p.number(value)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	placeholder := strconv.Itoa(numericPseudonymBase + n)
+	p.numeric[placeholder] = n
+	return json.Number(placeholder)
+}
+
+// observeNumericPseudonym remembers the numeric placeholder read from the replayed response.
+// All the numbers in the pseudonymized fields of the recorded responses are placeholders, so no guessing is needed.
+func (p *Pseudonymizer) observeNumericPseudonym(value string) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= numericPseudonymBase {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.numeric[value] = n - numericPseudonymBase
+	p.observe(n - numericPseudonymBase)
+}
+
+// observe makes sure, that the new values don't get the number of already used placeholder. It expects p.mu to be held.
+func (p *Pseudonymizer) observe(n int) {
+	if n >= p.next {
+		p.next = n + 1
+	}
+}
+
+// pseudonymJSON returns the placeholder for JSON value, keeping its type. Strings are mapped to the placeholders,
+// and numbers to the numeric placeholders with the same number, e.g. 1000000001 for SANITIZED-1,
+// so that e.g. the numeric ID from response body and the ID in request path are mapped consistently.
+// The scalar values nested in objects and arrays are mapped, and booleans and nulls are kept as they are.
+func (p *Pseudonymizer) pseudonymJSON(v any) any {
+	switch v := v.(type) {
+	case string:
+		return p.Pseudonym(v)
+	case json.Number:
+		return p.numericPseudonym(v.String())
+	case map[string]any:
+		// keys are sorted, so that the placeholders are assigned in a deterministic order
+		for _, key := range sortedKeys(v) {
+			v[key] = p.pseudonymJSON(v[key])
+		}
+		return v
+	case []any:
+		for i, child := range v {
+			v[i] = p.pseudonymJSON(child)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// observeJSON remembers the placeholders of the JSON value read from the replayed response, and returns it as it is.
+func (p *Pseudonymizer) observeJSON(v any) any {
+	switch v := v.(type) {
+	case string:
+		p.Pseudonym(v)
+	case json.Number:
+		p.observeNumericPseudonym(v.String())
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			p.observeJSON(v[key])
+		}
+	case []any:
+		for _, child := range v {
+			p.observeJSON(child)
+		}
+	}
+	return v
+}
+
+// HeadersSanitizer works like HeadersSanitizer function, but replaces the values with consistent placeholders.
+func (p *Pseudonymizer) HeadersSanitizer(headers ...string) RequestSanitizer {
+	return headersSanitizer(p.Pseudonym, headers)
+}
+
+// QueryParamsSanitizer works like SanitizerQueryParams function, but replaces the values with consistent placeholders.
+func (p *Pseudonymizer) QueryParamsSanitizer(params ...string) RequestSanitizer {
+	return queryParamsSanitizer(p.Pseudonym, params)
+}
+
+// JSONBodySanitizer works like JSONBodySanitizer function, but replaces the values with consistent placeholders.
+func (p *Pseudonymizer) JSONBodySanitizer(paths ...string) RequestSanitizer {
+	return jsonBodySanitizer(p.pseudonymJSON, paths)
+}
+
+// PathSanitizer replaces the path segments of the request URL matching the regular expression with consistent placeholders,
+// e.g. regexp.MustCompile(`^u-[0-9]+$`) for /users/u-123/orders.
+func (p *Pseudonymizer) PathSanitizer(segment *regexp.Regexp) RequestSanitizer {
+	return RequestSanitizerFunc(func(req *http.Request) *http.Request {
+		req.URL = replacePathSegments(req.URL, func(s string) string {
+			if segment.MatchString(s) {
+				return p.Pseudonym(s)
+			}
+			return s
+		})
+		return req
+	})
+}
+
+// ResponseHeadersSanitizer works like ResponseHeadersSanitizer function, but replaces the values with consistent placeholders.
+func (p *Pseudonymizer) ResponseHeadersSanitizer(headers ...string) ResponseSanitizer {
+	return responseHeadersSanitizer(p.Pseudonym, headers)
+}
+
+// ResponseJSONFieldsSanitizer works like ResponseJSONFieldsSanitizer function, but replaces the values with consistent placeholders.
+// In replay mode, the numbers read from the recorded fields are remembered as numeric placeholders.
+func (p *Pseudonymizer) ResponseJSONFieldsSanitizer(paths ...string) ResponseSanitizer {
+	sanitizer := responseJSONFieldsSanitizer(p.pseudonymJSON, paths)
+	observer := responseJSONFieldsSanitizer(p.observeJSON, paths)
+	return ResponseSanitizerFunc(func(resp *http.Response) *http.Response {
+		if isReplayedResponse(resp) {
+			return observer.SanitizeResponse(resp)
+		}
+		return sanitizer.SanitizeResponse(resp)
+	})
+}
+
+func replacePathSegments(u *url.URL, replace func(string) string) *url.URL {
+	if u == nil {
+		return u
+	}
+	segments := strings.Split(u.Path, "/")
+	for i, s := range segments {
+		if s != "" {
+			segments[i] = replace(s)
+		}
+	}
+	replaced := cloneURL(u)
+	replaced.Path = strings.Join(segments, "/")
+	replaced.RawPath = ""
+	return replaced
+}
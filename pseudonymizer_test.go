@@ -0,0 +1,230 @@
+package hypert
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPseudonymizer_Pseudonym(t *testing.T) {
+	p := NewPseudonymizer()
+	steps := []struct {
+		value    string
+		expected string
+	}{
+		{value: "alice", expected: "SANITIZED-1"},
+		{value: "bob", expected: "SANITIZED-2"},
+		{value: "alice", expected: "SANITIZED-1"},
+		{value: "", expected: ""},
+		{value: "SANITIZED", expected: "SANITIZED"},
+		{value: "SANITIZED-7", expected: "SANITIZED-7"},
+		{value: "carol", expected: "SANITIZED-8"},
+		{value: "1000000002", expected: "SANITIZED-9"},
+		{value: "dave", expected: "SANITIZED-10"},
+	}
+	for _, s := range steps {
+		if got := p.Pseudonym(s.value); got != s.expected {
+			t.Errorf("expected %q to be mapped to %q, got %q", s.value, s.expected, got)
+		}
+	}
+}
+
+func TestPseudonymizer_JSONKeepsTypes(t *testing.T) {
+	p := NewPseudonymizer()
+	resp := newTestResponse(`{"id":123,"active":true,"owner":{"id":123,"name":"alice","roles":["admin"]}}`)
+	sanitizedResp := p.ResponseJSONFieldsSanitizer("id", "active", "owner").SanitizeResponse(resp)
+	var got struct {
+		ID     int64
+		Active bool
+		Owner  struct {
+			ID    int64
+			Name  string
+			Roles []string
+		}
+	}
+	if err := json.NewDecoder(sanitizedResp.Body).Decode(&got); err != nil {
+		t.Fatalf("expected sanitized body to decode into typed struct: %v", err)
+	}
+	if got.ID != 1000000001 || got.Owner.ID != 1000000001 || !got.Active {
+		t.Errorf("expected numeric placeholders and booleans kept, got %+v", got)
+	}
+	if got.Owner.Name != "SANITIZED-2" || len(got.Owner.Roles) != 1 || got.Owner.Roles[0] != "SANITIZED-3" {
+		t.Errorf("expected nested strings to be mapped to placeholders, got %+v", got.Owner)
+	}
+	if pseudonym := p.Pseudonym("123"); pseudonym != "SANITIZED-1" {
+		t.Errorf("expected the digits of numeric value to be mapped to the same placeholder, got %s", pseudonym)
+	}
+}
+
+func TestPseudonymizer_NumericValueInPlaceholderRange(t *testing.T) {
+	p := NewPseudonymizer()
+	resp := newTestResponse(`{"id":123,"owner_id":1000123456}`)
+	sanitizedResp := p.ResponseJSONFieldsSanitizer("id", "owner_id").SanitizeResponse(resp)
+	body, err := io.ReadAll(sanitizedResp.Body)
+	if err != nil {
+		t.Fatalf("failed to read sanitized body: %v", err)
+	}
+	const expected = `{"id":1000000001,"owner_id":1000000002}`
+	if string(body) != expected {
+		t.Errorf("expected real value to be pseudonymized %s, got %s", expected, body)
+	}
+	if pseudonym := p.Pseudonym("1000123456"); pseudonym != "SANITIZED-2" {
+		t.Errorf("expected real value to keep its placeholder, got %s", pseudonym)
+	}
+	if pseudonym := p.Pseudonym("1000000001"); pseudonym != "SANITIZED-1" {
+		t.Errorf("expected issued numeric placeholder to be mapped to its placeholder, got %s", pseudonym)
+	}
+}
+
+func TestPseudonymizer_PathSanitizer(t *testing.T) {
+	p := NewPseudonymizer()
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/users/u-123/orders/u-123?x=1", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	sanitizedReq := p.PathSanitizer(regexp.MustCompile(`^u-\d+$`)).SanitizeRequest(req)
+	const expected = "https://example.com/users/SANITIZED-1/orders/SANITIZED-1?x=1"
+	if got := sanitizedReq.URL.String(); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestPseudonymizer_RecordAndReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/me":
+			_, _ = io.WriteString(w, `{"id":"u-42","name":"Alice"}`)
+		case "/orders":
+			body, _ := io.ReadAll(r.Body)
+			if !strings.Contains(string(body), `"u-42"`) {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			_, _ = io.WriteString(w, `{"order":"o-1"}`)
+		}
+	}))
+	defer srv.Close()
+
+	store := NewMemoryStore()
+	run := func(t *testing.T, mode Mode, token string) {
+		p := NewPseudonymizer()
+		client := TestClient(t, false,
+			WithMode(mode),
+			WithStore(store),
+			WithNamingScheme(&SequentialNamingScheme{dir: "pseudonymized"}),
+			WithRequestSanitizer(ComposedRequestSanitizer(p.HeadersSanitizer("Authorization"), p.JSONBodySanitizer("user_id"))),
+			WithResponseSanitizer(p.ResponseJSONFieldsSanitizer("id")),
+			WithRequestValidator(ComposedRequestValidator(DefaultRequestValidator(), JSONBodyValidator())),
+		)
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/me", http.NoBody)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		req.Header.Set("Authorization", token)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		var me struct{ ID string }
+		err = json.NewDecoder(resp.Body).Decode(&me)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to decode user: %v", err)
+		}
+
+		req, err = http.NewRequest(http.MethodPost, srv.URL+"/orders", strings.NewReader(`{"user_id":"`+me.ID+`"}`))
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		req.Header.Set("Authorization", token)
+		resp, err = client.Do(req)
+		if err != nil {
+			t.Fatalf("failed to create order: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status 200, got %d", resp.StatusCode)
+		}
+	}
+
+	t.Run("record", func(t *testing.T) {
+		run(t, ModeRecord, "Bearer real")
+	})
+	req, body, err := readStoredRequest(store, "pseudonymized/1.req.http")
+	if err != nil {
+		t.Fatalf("failed to read stored request: %v", err)
+	}
+	if req.Header.Get("Authorization") != "SANITIZED-1" || string(body) != `{"user_id":"SANITIZED-2"}` {
+		t.Errorf("unexpected stored request: %s %s", req.Header.Get("Authorization"), body)
+	}
+	t.Run("replay", func(t *testing.T) {
+		run(t, ModeReplay, "Bearer dummy")
+	})
+}
+
+func TestPseudonymizer_NumericRecordAndReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/me":
+			_, _ = io.WriteString(w, `{"id":1000123456}`)
+		case "/users/1000123456":
+			_, _ = io.WriteString(w, `{"name":"Alice"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	store := NewMemoryStore()
+	run := func(t *testing.T, mode Mode) {
+		p := NewPseudonymizer()
+		client := TestClient(t, false,
+			WithMode(mode),
+			WithStore(store),
+			WithNamingScheme(&SequentialNamingScheme{dir: "numeric"}),
+			WithRequestSanitizer(p.PathSanitizer(regexp.MustCompile(`^\d+$`))),
+			WithResponseSanitizer(p.ResponseJSONFieldsSanitizer("id")),
+		)
+
+		resp, err := client.Get(srv.URL + "/me")
+		if err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		var me struct{ ID int64 }
+		err = json.NewDecoder(resp.Body).Decode(&me)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to decode user: %v", err)
+		}
+
+		resp, err = client.Get(srv.URL + "/users/" + strconv.FormatInt(me.ID, 10))
+		if err != nil {
+			t.Fatalf("failed to get user details: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status 200, got %d", resp.StatusCode)
+		}
+	}
+
+	t.Run("record", func(t *testing.T) {
+		run(t, ModeRecord)
+	})
+	req, _, err := readStoredRequest(store, "numeric/1.req.http")
+	if err != nil {
+		t.Fatalf("failed to read stored request: %v", err)
+	}
+	if req.URL.Path != "/users/SANITIZED-1" {
+		t.Errorf("expected user ID in path to be pseudonymized, got %s", req.URL.Path)
+	}
+	t.Run("replay", func(t *testing.T) {
+		run(t, ModeReplay)
+	})
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 )
 
 // NoOpRequestSanitizer is a sanitizer that doesn't modify the request
@@ -17,38 +16,106 @@ func (NoOpRequestSanitizer) SanitizeRequest(req *http.Request) *http.Request {
 }
 
 type recordTransport struct {
+	t             T
 	httpTransport http.RoundTripper
 	namingScheme  NamingScheme
 	sanitizer     RequestSanitizer
+	respSanitizer ResponseSanitizer
 	transform     ResponseTransform
 	transformMode TransformRespMode
+	store         Store
+	leakGuard     *leakGuard
+	// streamThreshold is the size of the bodies, above which they are streamed. Streaming is disabled, if it's not positive.
+	streamThreshold int64
+	sidecarBodies   bool
+	// decoder decodes the stored response bodies. They are stored as they are, if it's nil.
+	decoder      contentCodings
+	recordErrors bool
 }
 
 func (d *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	if d.httpTransport == nil {
-		d.httpTransport = http.DefaultTransport
+	if err := spoolRequestBody(req, d.streamThreshold); err != nil {
+		return nil, fmt.Errorf("spool body: %w", err)
 	}
+	_, sanitizedData, err := sanitizedRequestData(d.sanitizer, req)
+	if err != nil {
+		closeSpooledBody(req.Body)
+		return nil, err
+	}
+
+	// file names are resolved the same way replay mode does it, so that the recorded files can be replayed later on.
+	reqFile, respFile := d.namingScheme.FileNames(sanitizedData)
+	return d.record(req, reqFile, respFile)
+}
 
-	reqData, err := requestDataFromRequest(req)
+// sanitizedRequestData returns the data of the request and of its sanitized clone, which the file names are resolved from.
+// The request might be sent, so sanitizer can't modify it in place.
+func sanitizedRequestData(sanitizer RequestSanitizer, req *http.Request) (reqData, sanitizedData RequestData, err error) {
+	reqData, err = requestDataFromRequest(req)
 	if err != nil {
-		return nil, fmt.Errorf("get request data: %w", err)
+		return RequestData{}, RequestData{}, fmt.Errorf("get request data: %w", err)
 	}
 
-	reqFile, respFile := d.namingScheme.FileNames(reqData)
-	req, err = d.dumpReqToFile(reqFile, req)
+	reqClone := req.Clone(req.Context())
+	if spool, ok := spooledBodyOf(req.Body); ok {
+		body, err := spool.open()
+		if err != nil {
+			return RequestData{}, RequestData{}, err
+		}
+		defer body.Close()
+		reqClone.Body = body
+	} else {
+		req.Body = io.NopCloser(bytes.NewReader(reqData.BodyBytes))
+		reqClone.Body = io.NopCloser(bytes.NewReader(reqData.BodyBytes))
+	}
+	sanitizedData, err = requestDataFromRequest(sanitizer.SanitizeRequest(reqClone))
 	if err != nil {
+		return RequestData{}, RequestData{}, fmt.Errorf("get sanitized request data: %w", err)
+	}
+	return reqData, sanitizedData, nil
+}
+
+// record makes the actual HTTP call and stores the request and response pair under given file names.
+// The bodies, that are not passed on, are closed on error, so that the temporary files of the streamed ones are removed.
+func (d *recordTransport) record(req *http.Request, reqFile, respFile string) (*http.Response, error) {
+	if d.httpTransport == nil {
+		d.httpTransport = http.DefaultTransport
+	}
+	// the request is stored together with the response, once both of them passed the leak guard,
+	// so that no request file without the response is left, which hybrid mode would try to replay.
+	dumpedReq, storeReq, err := d.dumpReqToFile(reqFile, req)
+	if err != nil {
+		closeSpooledBody(req.Body)
 		return nil, err
 	}
+	req = dumpedReq
 
 	resp, err := d.httpTransport.RoundTrip(req)
 	if err != nil {
+		if d.recordErrors {
+			storeErr, dumpErr := d.dumpErrToFile(respFile, err)
+			if dumpErr != nil {
+				return nil, dumpErr
+			}
+			if err := storeRecording(storeReq, storeErr); err != nil {
+				return nil, err
+			}
+		}
 		return nil, err
 	}
 	if d.transformMode == TransformRespModeOnRecord || d.transformMode == TransformRespModeAlways {
 		resp = d.transform.TransformResponse(resp)
 	}
-	resp, err = d.dumpRespToFile(respFile, req, resp)
+	dumpedResp, storeResp, err := d.dumpRespToFile(respFile, req, resp)
 	if err != nil {
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		return nil, err
+	}
+	resp = dumpedResp
+	if err := storeRecording(storeReq, storeResp); err != nil {
+		resp.Body.Close()
 		return nil, err
 	}
 	if d.transformMode == TransformRespModeRuntime {
@@ -58,57 +125,310 @@ func (d *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return resp, nil
 }
 
-func (d *recordTransport) dumpReqToFile(name string, req *http.Request) (*http.Request, error) {
+// storeRecording stores the request and then the response of the recording, that were dumped before.
+func storeRecording(storeReq, storeResp func() error) error {
+	if err := storeReq(); err != nil {
+		return err
+	}
+	return storeResp()
+}
+
+func (d *recordTransport) getStore() Store {
+	if d.store == nil {
+		return &FileStore{}
+	}
+	return d.store
+}
+
+// dumpReqToFile dumps the sanitized request and checks it for leaks. The returned function stores the dump.
+func (d *recordTransport) dumpReqToFile(name string, req *http.Request) (*http.Request, func() error, error) {
 	if req.Body == nil {
 		req.Body = http.NoBody
 	}
+	if spool, ok := spooledBodyOf(req.Body); ok {
+		store, err := d.dumpStreamedReqToFile(name, req, spool)
+		return req, store, err
+	}
 	reqClone := req.Clone(req.Context())
 	var originalReqBody bytes.Buffer
 	teeReader := io.TeeReader(req.Body, &originalReqBody)
 	reqClone.Body = io.NopCloser(teeReader)
 	sanitizedReq := d.sanitizer.SanitizeRequest(reqClone)
 
-	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
-	if err != nil {
-		return nil, err
-	}
-	err = sanitizedReq.WriteProxy(f)
+	var buf bytes.Buffer
+	err := sanitizedReq.WriteProxy(&buf)
 	if err != nil {
-		return nil, fmt.Errorf("write request to file %s: %w", name, err)
+		return nil, nil, fmt.Errorf("write request %s: %w", name, err)
 	}
-	if err := f.Close(); err != nil {
-		return nil, fmt.Errorf("close file %s: %w", name, err)
+	if err := d.checkLeaks(name, buf.Bytes()); err != nil {
+		return nil, nil, err
 	}
 
 	req.Body = io.NopCloser(&originalReqBody)
-	return req, nil
+	return req, func() error {
+		if err := d.writeRequest(name, buf.Bytes()); err != nil {
+			return fmt.Errorf("store request: %w", err)
+		}
+		return nil
+	}, nil
 }
 
-func (d *recordTransport) dumpRespToFile(name string, req *http.Request, resp *http.Response) (*http.Response, error) {
+// dumpRespToFile works like dumpReqToFile, but for the response. The returned response is the original one.
+func (d *recordTransport) dumpRespToFile(name string, req *http.Request, resp *http.Response) (*http.Response, func() error, error) {
+	if err := spoolResponseBody(resp, d.streamThreshold); err != nil {
+		return nil, nil, fmt.Errorf("spool body: %w", err)
+	}
+	if spool, ok := spooledBodyOf(resp.Body); ok {
+		store, err := d.dumpStreamedRespToFile(name, resp, spool)
+		return resp, store, err
+	}
 	var buf bytes.Buffer
 	err := resp.Write(&buf)
 	if err != nil {
+		return nil, nil, err
+	}
+
+	respBytes := buf.Bytes()
+	storedBytes, err := d.sanitizeResponse(respBytes, req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sanitize response: %w", err)
+	}
+	if err := d.checkLeaks(name, storedBytes); err != nil {
+		return nil, nil, err
+	}
+
+	resp, err = http.ReadResponse(bufio.NewReader(bytes.NewReader(respBytes)), req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resp, func() error {
+		if err := d.writeResponse(name, storedBytes, req); err != nil {
+			return fmt.Errorf("store response: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// dumpErrToFile dumps the error recording of the failed call, that is stored instead of the response.
+func (d *recordTransport) dumpErrToFile(name string, callErr error) (func() error, error) {
+	var buf bytes.Buffer
+	if err := writeErrorRecording(&buf, errorRecordingFields(callErr)); err != nil {
+		return nil, fmt.Errorf("write error recording %s: %w", name, err)
+	}
+	if err := d.checkLeaks(name, buf.Bytes()); err != nil {
 		return nil, err
 	}
+	return func() error {
+		if err := d.getStore().WriteResponse(name, &buf); err != nil {
+			return fmt.Errorf("store error recording: %w", err)
+		}
+		return nil
+	}, nil
+}
 
-	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
+// sanitizeResponse returns the dump of the sanitized copy of the response, so that the original response is left untouched.
+func (d *recordTransport) sanitizeResponse(respBytes []byte, req *http.Request) ([]byte, error) {
+	if d.respSanitizer == nil && d.decoder == nil {
+		return respBytes, nil
+	}
+	respCopy, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(respBytes)), req)
 	if err != nil {
-		return nil, fmt.Errorf("open file %s: %w", name, err)
+		return nil, err
 	}
-	respBytes := buf.Bytes()
+	if d.decoder != nil {
+		respCopy, err = d.decoder.decodeResponse(respCopy)
+		if err != nil {
+			err = fmt.Errorf("decoded bodies: %w", err)
+			d.t.Fatalf("hypert: %v", err)
+			return nil, err
+		}
+	}
+	sanitized := respCopy
+	if d.respSanitizer != nil {
+		sanitized = d.respSanitizer.SanitizeResponse(respCopy)
+	}
+	var buf bytes.Buffer
+	if err := sanitized.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// checkLeaks fails the test, if the leak guard finds any secrets in the dump, that is about to be stored.
+func (d *recordTransport) checkLeaks(name string, dump []byte) error {
+	if err := d.leakGuard.check(dump); err != nil {
+		err = fmt.Errorf("leak guard: %s: %w; adjust the sanitizers, so that it's not stored", name, err)
+		d.t.Fatalf("hypert: %v", err)
+		return err
+	}
+	return nil
+}
+
+// checkSpooledLeaks works like checkLeaks, but for the spooled body, which is checked in chunks.
+func (d *recordTransport) checkSpooledLeaks(name string, spool *spooledBody) error {
+	if d.leakGuard == nil {
+		return nil
+	}
+	body, err := spool.open()
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := d.leakGuard.checkReader(body); err != nil {
+		err = fmt.Errorf("leak guard: %s: %w; streamed bodies are not sanitized, so they can't be stored", name, err)
+		d.t.Fatalf("hypert: %v", err)
+		return err
+	}
+	return nil
+}
+
+// checkUnsanitized fails the test, if a body sanitizer skipped the streamed body, and the leak guard can't check it instead.
+func (d *recordTransport) checkUnsanitized(name string, spool *spooledBody) error {
+	if !spool.unsanitized || d.leakGuard != nil {
+		return nil
+	}
+	err := fmt.Errorf("%s: streamed bodies are not passed to the body sanitizers, so the body would be stored as it is; "+
+		"enable WithLeakGuard option to check it, or raise WithBodyStreaming threshold", name)
+	d.t.Fatalf("hypert: %v", err)
+	return err
+}
 
-	_, err = io.Copy(f, bytes.NewReader(respBytes))
+// dumpStreamedReqToFile stores the spooled body in the sidecar file, and dumps the head of the request. The returned function stores the head.
+// The sanitizers may modify the head only.
+func (d *recordTransport) dumpStreamedReqToFile(name string, req *http.Request, spool *spooledBody) (func() error, error) {
+	body, err := spool.open()
 	if err != nil {
 		return nil, err
 	}
-	if err := f.Close(); err != nil {
-		return nil, fmt.Errorf("close file %s: %w", name, err)
+	reqClone := req.Clone(req.Context())
+	reqClone.Body = body
+	sanitizedReq := d.sanitizer.SanitizeRequest(reqClone)
+	if sanitizedReq.Body != nil {
+		sanitizedReq.Body.Close()
+	}
+	body.Close()
+	if err := d.checkUnsanitized(name, spool); err != nil {
+		return nil, err
 	}
+	ref := bodyFileRef{name: bodyFileName(name, sidecarExtension(sanitizedReq.Header)), size: spool.size, sha256: spool.sha256}
+	setBodyFileRef(sanitizedReq.Header, ref)
 
-	resp, err = http.ReadResponse(bufio.NewReader(bytes.NewReader(respBytes)), req)
+	var buf bytes.Buffer
+	if err := writeRequestHead(&buf, sanitizedReq); err != nil {
+		return nil, fmt.Errorf("write request %s: %w", name, err)
+	}
+	if err := d.checkLeaks(name, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := d.checkSpooledLeaks(ref.name, spool); err != nil {
+		return nil, err
+	}
+	// the temporary file is removed once the request is sent, so the body is stored right away.
+	// The sidecar file is not replayed without the request file, which is stored along with the response.
+	if err := d.writeBody(ref.name, spool); err != nil {
+		return nil, fmt.Errorf("store request body: %w", err)
+	}
+	return func() error {
+		if err := d.getStore().WriteRequest(name, &buf); err != nil {
+			return fmt.Errorf("store request: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// dumpStreamedRespToFile works like dumpStreamedReqToFile, but for the response.
+// The response, that is returned to the client, reads the spooled body.
+func (d *recordTransport) dumpStreamedRespToFile(name string, resp *http.Response, spool *spooledBody) (func() error, error) {
+	body, err := spool.open()
 	if err != nil {
 		return nil, err
 	}
+	defer body.Close()
+	respCopy := *resp
+	respCopy.Header = resp.Header.Clone()
+	respCopy.Body = body
+	sanitized := &respCopy
+	if d.respSanitizer != nil {
+		sanitized = d.respSanitizer.SanitizeResponse(sanitized)
+	}
+	if sanitized.Body != nil {
+		sanitized.Body.Close()
+	}
+	if err := d.checkUnsanitized(name, spool); err != nil {
+		return nil, err
+	}
+	ref := bodyFileRef{name: bodyFileName(name, sidecarExtension(sanitized.Header)), size: spool.size, sha256: spool.sha256}
+	setBodyFileRef(sanitized.Header, ref)
 
-	return resp, nil
+	var buf bytes.Buffer
+	if err := writeResponseHead(&buf, sanitized); err != nil {
+		return nil, fmt.Errorf("write response %s: %w", name, err)
+	}
+	if err := d.checkLeaks(name, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := d.checkSpooledLeaks(ref.name, spool); err != nil {
+		return nil, err
+	}
+	return func() error {
+		if err := d.writeBody(ref.name, spool); err != nil {
+			return fmt.Errorf("store response body: %w", err)
+		}
+		if err := d.getStore().WriteResponse(name, &buf); err != nil {
+			return fmt.Errorf("store response: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+func (d *recordTransport) writeBody(name string, spool *spooledBody) error {
+	body, err := spool.open()
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return d.writeSidecar(name, body)
+}
+
+func (d *recordTransport) writeSidecar(name string, r io.Reader) error {
+	bs, err := bodyStore(d.getStore())
+	if err != nil {
+		return err
+	}
+	return bs.WriteBody(name, r)
+}
+
+// writeRequest stores the request dump. If sidecar bodies are enabled, the body is stored in the sidecar file.
+func (d *recordTransport) writeRequest(name string, dump []byte) error {
+	if d.sidecarBodies {
+		head, ref, content, ok, err := splitRequestDump(name, dump)
+		if err != nil {
+			return fmt.Errorf("split request %s: %w", name, err)
+		}
+		if ok {
+			if err := d.writeSidecar(ref.name, bytes.NewReader(content)); err != nil {
+				return fmt.Errorf("store request body: %w", err)
+			}
+			dump = head
+		}
+	}
+	return d.getStore().WriteRequest(name, bytes.NewReader(dump))
+}
+
+// writeResponse works like writeRequest, but for the response.
+func (d *recordTransport) writeResponse(name string, dump []byte, req *http.Request) error {
+	if d.sidecarBodies {
+		head, ref, content, ok, err := splitResponseDump(name, dump, req)
+		if err != nil {
+			return fmt.Errorf("split response %s: %w", name, err)
+		}
+		if ok {
+			if err := d.writeSidecar(ref.name, bytes.NewReader(content)); err != nil {
+				return fmt.Errorf("store response body: %w", err)
+			}
+			dump = head
+		}
+	}
+	return d.getStore().WriteResponse(name, bytes.NewReader(dump))
 }
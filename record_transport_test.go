@@ -231,3 +231,71 @@ func TestRecordTransport_NilBody(t *testing.T) {
 		t.Errorf("expected response file to contain 'response body', got %s", string(respContent))
 	}
 }
+
+func TestRecordTransport_SanitizesOnlyStoredResponse(t *testing.T) {
+	store := NewMemoryStore()
+	resp := newTestResponse(`{"token":"secret"}`)
+	resp.Header.Set("Set-Cookie", "session=abc")
+	rt := recordTransport{
+		httpTransport: &mockRoundTripper{resp: resp}, //nolint:bodyclose // body is closed by the transport
+		namingScheme:  &staticNamingScheme{reqFile: "0.req.http", respFile: "0.resp.http"},
+		sanitizer:     NoOpRequestSanitizer{},
+		respSanitizer: ComposedResponseSanitizer(DefaultResponseSanitizer(), ResponseJSONFieldsSanitizer("token")),
+		store:         store,
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	got, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer got.Body.Close()
+	body, err := io.ReadAll(got.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if string(body) != `{"token":"secret"}` || got.Header.Get("Set-Cookie") != "session=abc" {
+		t.Errorf("expected live response to be untouched, got %s with cookie %s", body, got.Header.Get("Set-Cookie"))
+	}
+
+	stored, storedBody, err := readStoredResponse(store, "0.resp.http", req)
+	if err != nil {
+		t.Fatalf("failed to read stored response: %v", err)
+	}
+	if string(storedBody) != `{"token":"SANITIZED"}` || stored.Header.Get("Set-Cookie") != "session=SANITIZED" {
+		t.Errorf("expected stored response to be sanitized, got %s with cookie %s", storedBody, stored.Header.Get("Set-Cookie"))
+	}
+}
+
+func TestRecordTransport_SanitizedBodyNaming(t *testing.T) {
+	store := NewMemoryStore()
+	scheme := &ContentHashNamingScheme{dir: "login"}
+	roundTrip := func(mode Mode) *http.Response {
+		client := TestClient(t, false,
+			WithMode(mode),
+			WithStore(store),
+			WithNamingScheme(scheme),
+			WithRequestSanitizer(JSONBodySanitizer("password")),
+			WithParentHTTPClient(&http.Client{Transport: &mockRoundTripper{resp: newTestResponse(`{"ok":true}`)}}),
+		)
+		resp, err := client.Post("https://example.com/login", "application/json", bytes.NewBufferString(`{"user":"joe","password":"secret"}`))
+		if err != nil {
+			t.Fatalf("failed to make request in %s mode: %v", mode, err)
+		}
+		return resp
+	}
+
+	roundTrip(ModeRecord).Body.Close()
+	resp := roundTrip(ModeReplay)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if string(body) != `{"ok":true}` {
+		t.Errorf("expected recorded response to be replayed, got %s", body)
+	}
+}
@@ -3,11 +3,13 @@ package hypert
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 )
 
 type replayTransport struct {
@@ -15,28 +17,52 @@ type replayTransport struct {
 	scheme        NamingScheme
 	validator     RequestValidator
 	sanitizer     RequestSanitizer
+	respSanitizer ResponseSanitizer
 	transform     ResponseTransform
 	transformMode TransformRespMode
+	used          *usedRecordings
+	store         Store
+	// streamThreshold is the size of the bodies, above which they are streamed. Streaming is disabled, if it's not positive.
+	streamThreshold int64
+	// codings encode the bodies, that were stored decoded.
+	codings contentCodings
 }
 
 func (d *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := spoolRequestBody(req, d.streamThreshold); err != nil {
+		return nil, fmt.Errorf("spool body: %w", err)
+	}
 	sanitizedReq := d.sanitizer.SanitizeRequest(req)
 	requestData, err := requestDataFromRequest(sanitizedReq)
+	closeSpooledBody(req.Body)
 	if err != nil {
 		return nil, err
 	}
 	reqFile, respFile := d.scheme.FileNames(requestData)
+	return d.replay(req, requestData, reqFile, respFile)
+}
+
+// replay validates the request against the one stored in reqFile and returns the response stored in respFile.
+// requestData should be taken from the already sanitized request.
+func (d *replayTransport) replay(req *http.Request, requestData RequestData, reqFile, respFile string) (*http.Response, error) {
 	recordedReq, err := d.readReqFromFile(reqFile)
 	if err != nil {
 		d.t.Fatalf("read request %s from file: %v", requestData, err)
 		return nil, err
 	}
+	d.used.markUsed(reqFile)
 
 	err = d.validator.Validate(d.t, recordedReq, requestData)
-	if err != nil {
+	if mismatches, ok := asValidationError(err); ok {
+		d.t.Errorf("hypert: %s", formatDiff(requestData, reqFile, mismatches, useColors()))
+	} else if err != nil {
 		return nil, fmt.Errorf("request validation failed: %w", err)
 	}
+	return d.respond(req, respFile)
+}
 
+// respond returns the response stored in respFile, transformed according to the transform mode.
+func (d *replayTransport) respond(req *http.Request, respFile string) (*http.Response, error) {
 	respFromFile, err := d.readRespFromFile(respFile, req)
 	if err != nil {
 		return nil, err
@@ -55,20 +81,48 @@ func (d *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return respFromFile, nil
 }
 
-const helpMsgReplayFileDoesntExist = `make sure, to record the request first using recordModeOn parameter in the TestClient.`
+const helpMsgReplayFileDoesntExist = `make sure, to record the request first using recordModeOn parameter or ModeRecord/ModeHybrid mode in the TestClient.`
+
+func (d *replayTransport) getStore() Store {
+	if d.store == nil {
+		return &FileStore{}
+	}
+	return d.store
+}
 
 func (d *replayTransport) readReqFromFile(name string) (RequestData, error) {
-	f, err := os.OpenFile(name, os.O_RDONLY, 0o000)
+	f, err := d.getStore().OpenRequest(name)
 	if errors.Is(err, os.ErrNotExist) {
 		return RequestData{}, fmt.Errorf("file %s does not exist -  %s", name, helpMsgReplayFileDoesntExist)
 	}
 	if err != nil {
 		return RequestData{}, fmt.Errorf("open file %s: %w", name, err)
 	}
+	defer f.Close()
 	gotReq, err := http.ReadRequest(bufio.NewReader(f))
 	if err != nil {
 		return RequestData{}, fmt.Errorf("read request from file %s: %w", name, err)
 	}
+	ref, sidecar, err := takeBodyFileRef(gotReq.Header, name)
+	if err != nil {
+		return RequestData{}, fmt.Errorf("read request from file %s: %w", name, err)
+	}
+	if sidecar && d.isStreamed(ref) {
+		return RequestData{
+			Headers:    gotReq.Header.Clone(),
+			URL:        cloneURL(gotReq.URL),
+			Method:     gotReq.Method,
+			BodySize:   ref.size,
+			BodySHA256: ref.sha256,
+		}, nil
+	}
+	if sidecar {
+		body, err := readSidecarBody(d.getStore(), ref)
+		if err != nil {
+			return RequestData{}, fmt.Errorf("read request from file %s: %w", name, err)
+		}
+		gotReq.Body = io.NopCloser(bytes.NewReader(body))
+	}
 	reqData, err := requestDataFromRequest(gotReq)
 	if err != nil {
 		return RequestData{}, fmt.Errorf("get request data: %w", err)
@@ -76,8 +130,13 @@ func (d *replayTransport) readReqFromFile(name string) (RequestData, error) {
 	return reqData, nil
 }
 
+// isStreamed is true, if the body in the sidecar file should be streamed, instead of being read into memory.
+func (d *replayTransport) isStreamed(ref bodyFileRef) bool {
+	return d.streamThreshold > 0 && ref.size > d.streamThreshold && ref.format == ""
+}
+
 func (d *replayTransport) readRespFromFile(name string, req *http.Request) (*http.Response, error) {
-	f, err := os.OpenFile(name, os.O_RDONLY, 0o000)
+	f, err := d.getStore().OpenResponse(name)
 	if errors.Is(err, os.ErrNotExist) {
 		return nil, fmt.Errorf("file %s does not exist -  %s", name, helpMsgReplayFileDoesntExist)
 	}
@@ -91,5 +150,93 @@ func (d *replayTransport) readRespFromFile(name string, req *http.Request) (*htt
 	if err != nil {
 		return nil, err
 	}
-	return http.ReadResponse(bufio.NewReader(&buf), req)
+	if isErrorRecording(buf.Bytes()) {
+		fields, err := readErrorRecording(&buf)
+		if err != nil {
+			return nil, fmt.Errorf("read error recording %s: %w", name, err)
+		}
+		return nil, recordedError(fields)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(&buf), req)
+	if err != nil {
+		return nil, err
+	}
+	ref, sidecar, err := takeBodyFileRef(resp.Header, name)
+	if err != nil {
+		return nil, fmt.Errorf("read response from file %s: %w", name, err)
+	}
+	if sidecar && d.isStreamed(ref) {
+		bs, err := bodyStore(d.getStore())
+		if err != nil {
+			return nil, err
+		}
+		body, err := bs.OpenBody(ref.name)
+		if err != nil {
+			return nil, fmt.Errorf("open body of %s: %w", name, err)
+		}
+		d.observeResponse(resp, nil)
+		resp.Body = body
+		resp.ContentLength = ref.size
+		return resp, nil
+	}
+
+	var body []byte
+	if sidecar {
+		body, err = readSidecarBody(d.getStore(), ref)
+		if err == nil {
+			// the length might have changed, if the sidecar file was edited
+			resp.ContentLength = int64(len(body))
+			resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		}
+	} else {
+		body, err = io.ReadAll(resp.Body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read response from file %s: %w", name, err)
+	}
+	d.observeResponse(resp, body)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	acceptEncoding := ""
+	if req != nil {
+		acceptEncoding = req.Header.Get("Accept-Encoding")
+	}
+	if err := d.codings.encodeResponse(resp, body, acceptEncoding); err != nil {
+		return nil, fmt.Errorf("read response from file %s: %w", name, err)
+	}
+	return resp, nil
+}
+
+// observeResponse runs the response sanitizer on a copy of the replayed response, and discards the result.
+// The recorded response is already sanitized, but stateful sanitizers, e.g. Pseudonymizer's ones,
+// need to see it to stay in the same state as they were during recording.
+// The body is nil, if it's streamed.
+func (d *replayTransport) observeResponse(resp *http.Response, body []byte) {
+	if d.respSanitizer == nil {
+		return
+	}
+	respCopy := *resp
+	respCopy.Header = resp.Header.Clone()
+	req := resp.Request
+	if req == nil {
+		req = &http.Request{}
+	}
+	respCopy.Request = req.WithContext(context.WithValue(req.Context(), replayedResponseKey{}, true))
+	respCopy.Body = http.NoBody
+	if body != nil {
+		respCopy.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	sanitized := d.respSanitizer.SanitizeResponse(&respCopy)
+	if sanitized.Body != nil {
+		_, _ = io.Copy(io.Discard, sanitized.Body)
+		sanitized.Body.Close()
+	}
+}
+
+// replayedResponseKey marks the context of the responses, that are observed in replay mode.
+type replayedResponseKey struct{}
+
+// isReplayedResponse tells, whether the response passed to the sanitizer is the recorded one observed in replay mode,
+// so that the sanitizer can learn its placeholders instead of issuing the new ones.
+func isReplayedResponse(resp *http.Response) bool {
+	return resp.Request != nil && resp.Request.Context().Value(replayedResponseKey{}) != nil
 }
@@ -253,3 +253,50 @@ func TestReplayTransport_TransformModes(t *testing.T) {
 		})
 	}
 }
+
+func TestReplayTransport_ValidationDiff(t *testing.T) {
+	store := NewMemoryStore()
+	const recordedReq = "POST https://example.com/users HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/json\r\nContent-Length: 13\r\n\r\n{\"name\":\"a\"}\n"
+	if err := store.WriteRequest("0.req.http", strings.NewReader(recordedReq)); err != nil {
+		t.Fatalf("failed to write request: %v", err)
+	}
+	if err := store.WriteResponse("0.resp.http", strings.NewReader("HTTP/1.1 201 Created\r\nContent-Length: 0\r\n\r\n")); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	t.Setenv("NO_COLOR", "1")
+
+	mockedT := &mockT{}
+	transport := replayTransport{
+		t:         mockedT,
+		scheme:    &staticNamingScheme{reqFile: "0.req.http", respFile: "0.resp.http"},
+		validator: ComposedRequestValidator(DefaultRequestValidator(), BodyValidator()),
+		sanitizer: NoOpRequestSanitizer{},
+		store:     store,
+	}
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/accounts", strings.NewReader(`{"name":"b"}`))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected the recorded response to be returned, got %v", err)
+	}
+	resp.Body.Close()
+
+	const expected = "hypert: request POST https://example.com/accounts doesn't match the recording 0.req.http\n" +
+		"--- recorded\n" +
+		"+++ got\n" +
+		"@@ path @@\n" +
+		"- '/users'\n" +
+		"+ '/accounts'\n" +
+		"@@ body $.name @@\n" +
+		"- \"a\"\n" +
+		"+ \"b\""
+	if !mockedT.failed || mockedT.fatal {
+		t.Errorf("expected mocked T to fail, but not fatally")
+	}
+	if mockedT.msg != expected {
+		t.Errorf("expected message:\n%s\ngot:\n%s", expected, mockedT.msg)
+	}
+}
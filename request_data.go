@@ -2,6 +2,8 @@ package hypert
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,17 +12,39 @@ import (
 
 // RequestData is some data related to the request, that can be used to create filename in the NamingScheme's FileNames method implementations or during request validation.
 // The fields are cloned from request's fields and their modification will not affect actual request's values.
+//
+// BodyBytes is nil, if the body was streamed, see WithBodyStreaming. BodySize and BodySHA256 are set for all the bodies.
 type RequestData struct {
 	Headers   http.Header
 	URL       *url.URL
 	Method    string
 	BodyBytes []byte
+	// BodySize is the length of the body in bytes.
+	BodySize int64
+	// BodySHA256 is the hex-encoded SHA-256 digest of the body.
+	BodySHA256 string
 }
 
 func (r RequestData) String() string {
 	return fmt.Sprintf("%s %s", r.Method, r.URL)
 }
 
+// clone returns a deep copy of the request data, so that it can be modified e.g. by validators without affecting the original.
+func (r RequestData) clone() RequestData {
+	var body []byte
+	if r.BodyBytes != nil {
+		body = append([]byte{}, r.BodyBytes...)
+	}
+	return RequestData{
+		Headers:    r.Headers.Clone(),
+		URL:        cloneURL(r.URL),
+		Method:     r.Method,
+		BodyBytes:  body,
+		BodySize:   r.BodySize,
+		BodySHA256: r.BodySHA256,
+	}
+}
+
 func cloneURL(u *url.URL) *url.URL {
 	if u == nil { // this shouldn't actually happen, unless there is very weird injected clients' transport setup
 		return nil
@@ -35,10 +59,26 @@ func cloneURL(u *url.URL) *url.URL {
 	return &uCopy
 }
 
+// isStreamed is true, if the body was streamed and it's available only by size and digest.
+func (r RequestData) isStreamed() bool {
+	return r.BodyBytes == nil && r.BodySHA256 != ""
+}
+
+// requestDataFromRequest reads the request body, and restores it, so that the request can be sent.
+// The streamed bodies are not read, see spoolRequestBody.
 func requestDataFromRequest(req *http.Request) (RequestData, error) {
 	if req.Body == nil {
 		req.Body = http.NoBody
 	}
+	if spool, ok := spooledBodyOf(req.Body); ok {
+		return RequestData{
+			Headers:    req.Header.Clone(),
+			URL:        cloneURL(req.URL),
+			Method:     req.Method,
+			BodySize:   spool.size,
+			BodySHA256: spool.sha256,
+		}, nil
+	}
 	var originalReqBody bytes.Buffer
 	teeReader := io.TeeReader(req.Body, &originalReqBody)
 	req.Body = io.NopCloser(&originalReqBody)
@@ -47,10 +87,13 @@ func requestDataFromRequest(req *http.Request) (RequestData, error) {
 		return RequestData{}, fmt.Errorf("read request body: %w", err)
 	}
 
+	digest := sha256.Sum256(gotBodyBytes)
 	return RequestData{
-		Headers:   req.Header.Clone(),
-		URL:       cloneURL(req.URL),
-		Method:    req.Method,
-		BodyBytes: gotBodyBytes,
+		Headers:    req.Header.Clone(),
+		URL:        cloneURL(req.URL),
+		Method:     req.Method,
+		BodyBytes:  gotBodyBytes,
+		BodySize:   int64(len(gotBodyBytes)),
+		BodySHA256: hex.EncodeToString(digest[:]),
 	}, nil
 }
@@ -2,6 +2,9 @@ package hypert
 
 import "net/http"
 
+// sanitizedValue is the placeholder, which replaces sensitive values in the stored recordings.
+const sanitizedValue = "SANITIZED"
+
 // RequestSanitizer ensures, that no sensitive data is written to the request records.
 // The sanitized version would be stored, whilst the original one would be sent in the record mode.
 // It is allowed to mutate the request in place, because it is copied before invoking the RoundTrip method.
@@ -37,43 +40,80 @@ func ComposedRequestSanitizer(s ...RequestSanitizer) RequestSanitizer {
 // HeadersSanitizer sets listed headers to "SANITIZED".
 // Lookup DefaultHeadersSanitizer for a default value.
 func HeadersSanitizer(headers ...string) RequestSanitizer {
+	return headersSanitizer(sanitizeString, headers)
+}
+
+func headersSanitizer(replace func(string) string, headers []string) RequestSanitizer {
 	return RequestSanitizerFunc(func(req *http.Request) *http.Request {
 		for _, header := range headers {
-			if req.Header.Get(header) != "" {
-				req.Header.Set(header, "SANITIZED")
+			if v := req.Header.Get(header); v != "" {
+				req.Header.Set(header, replace(v))
 			}
 		}
 		return req
 	})
 }
 
+// sanitizeString replaces any value with "SANITIZED".
+func sanitizeString(_ string) string {
+	return sanitizedValue
+}
+
 // DefaultHeadersSanitizer is HeadersSanitizer with the most common headers that should be sanitized in most cases.
 func DefaultHeadersSanitizer() RequestSanitizer {
-	return HeadersSanitizer(
-		"Authorization",
-		"Cookie",
-		"X-Auth-Token",
-		"X-API-Key",
-		"Proxy-Authorization",
-		"X-Forwarded-For",
-		"Referrer",
-		"X-Secret",
-		"X-Access-Token",
-		"X-Client-Secret",
-		"X-Client-ID",
-		"X-Auth",
-		"X-Auth-Token",
-	)
+	return HeadersSanitizer(defaultSanitizedHeaders...)
+}
+
+// DefaultHeadersSanitizerExcept is DefaultHeadersSanitizer, that leaves listed headers to the more specific sanitizers,
+// e.g. Authorization header to aws.RequestSanitizer or JWTSanitizer, which keep the parts of it, that are useful in replay mode:
+//
+//	hypert.ComposedRequestSanitizer(
+//		hypert.DefaultHeadersSanitizerExcept("Authorization"),
+//		hypert.DefaultQueryParamsSanitizer(),
+//		hypert.JWTSanitizer(),
+//	)
+func DefaultHeadersSanitizerExcept(headers ...string) RequestSanitizer {
+	excluded := make(map[string]struct{}, len(headers))
+	for _, h := range headers {
+		excluded[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+	var sanitized []string
+	for _, h := range defaultSanitizedHeaders {
+		if _, ok := excluded[http.CanonicalHeaderKey(h)]; !ok {
+			sanitized = append(sanitized, h)
+		}
+	}
+	return HeadersSanitizer(sanitized...)
+}
+
+var defaultSanitizedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"X-Auth-Token",
+	"X-API-Key",
+	"Proxy-Authorization",
+	"X-Forwarded-For",
+	"Referrer",
+	"X-Secret",
+	"X-Access-Token",
+	"X-Client-Secret",
+	"X-Client-ID",
+	"X-Auth",
+	"X-Auth-Token",
 }
 
 // SanitizerQueryParams sets listed query params in stored request URL to SANITIZED value.
 // Lookup DefaultQueryParamsSanitizer for a default value.
 func SanitizerQueryParams(params ...string) RequestSanitizer {
+	return queryParamsSanitizer(sanitizeString, params)
+}
+
+func queryParamsSanitizer(replace func(string) string, params []string) RequestSanitizer {
 	return RequestSanitizerFunc(func(req *http.Request) *http.Request {
 		q := req.URL.Query()
 		for _, param := range params {
 			if q.Has(param) {
-				q.Set(param, "SANITIZED")
+				q.Set(param, replace(q.Get(param)))
 			}
 		}
 		req.URL.RawQuery = q.Encode()
@@ -1,8 +1,13 @@
 package hypert
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 // RequestValidator does assertions, that allows to make assertions on request that was caught by TestClient in the replay mode.
+// The differences should be returned as ValidationError, so that they are reported together with the ones found by other validators.
+// Calling t.Errorf is supported as well.
 type RequestValidator interface {
 	Validate(t T, recorded RequestData, got RequestData) error
 }
@@ -13,14 +18,23 @@ func (f RequestValidatorFunc) Validate(t T, recorded, got RequestData) error {
 	return f(t, recorded, got)
 }
 
+// ComposedRequestValidator runs all the passed validators, and merges the mismatches they return into a single ValidationError.
+// Errors other than ValidationError stop the validation and are returned as they are.
 func ComposedRequestValidator(validators ...RequestValidator) RequestValidator {
 	return RequestValidatorFunc(func(t T, recorded RequestData, got RequestData) error {
+		var mismatches []Mismatch
 		for _, validator := range validators {
-			if err := validator.Validate(t, recorded, got); err != nil {
-				return fmt.Errorf("request validation failed: %w", err)
+			err := validator.Validate(t, recorded, got)
+			if err == nil {
+				continue
+			}
+			if m, ok := asValidationError(err); ok {
+				mismatches = append(mismatches, m...)
+				continue
 			}
+			return fmt.Errorf("request validation failed: %w", err)
 		}
-		return nil
+		return validationError(mismatches)
 	})
 }
 
@@ -37,29 +51,19 @@ func DefaultRequestValidator() RequestValidator {
 func PathValidator() RequestValidator {
 	return RequestValidatorFunc(func(t T, recorded RequestData, got RequestData) error {
 		if recorded.URL.Path != got.URL.Path {
-			t.Errorf("expected path '%s', got '%s'", recorded.URL.Path, got.URL.Path)
+			return validationError([]Mismatch{{Field: "path", Expected: quoted(recorded.URL.Path), Got: quoted(got.URL.Path)}})
 		}
 		return nil
 	})
 }
 
 // QueryParamsValidator validates query parameters of the request.
-// It is not sensitive to the order of query parameters.
-func QueryParamsValidator() RequestValidator {
+// It is not sensitive to the order of query parameters, but all the values of repeated parameters are compared in order.
+// Lookup FieldValidatorOption implementations to ignore or loosely compare the parameters, that change on every call.
+func QueryParamsValidator(opts ...FieldValidatorOption) RequestValidator {
+	cfg := newFieldValidatorConfig(func(name string) string { return name }, opts)
 	return RequestValidatorFunc(func(t T, recorded RequestData, got RequestData) error {
-		recordedQ, gotQ := recorded.URL.Query(), got.URL.Query()
-
-		for key := range recordedQ {
-			recordedParam, gotParam := recordedQ.Get(key), gotQ.Get(key)
-			if recordedParam != gotParam {
-				t.Errorf("expected query parameter '%s' to be '%s', got '%s'", key, recordedParam, gotParam)
-			}
-			gotQ.Del(key)
-		}
-		for key := range gotQ {
-			t.Errorf("unexpected query parameter '%s' with value '%s'", key, gotQ.Get(key))
-		}
-		return nil
+		return validationError(cfg.diff("query parameter", recorded.URL.Query(), got.URL.Query()))
 	})
 }
 
@@ -67,8 +71,7 @@ func QueryParamsValidator() RequestValidator {
 func MethodValidator() RequestValidator {
 	return RequestValidatorFunc(func(t T, recorded RequestData, got RequestData) error {
 		if recorded.Method != got.Method {
-			t.Errorf("expected method '%s', got '%s'", recorded.Method, got.Method)
-			return nil
+			return validationError([]Mismatch{{Field: "method", Expected: quoted(recorded.Method), Got: quoted(got.Method)}})
 		}
 		return nil
 	})
@@ -78,34 +81,34 @@ func MethodValidator() RequestValidator {
 func SchemeValidator() RequestValidator {
 	return RequestValidatorFunc(func(t T, recorded RequestData, got RequestData) error {
 		if recorded.URL.Scheme != got.URL.Scheme {
-			t.Errorf("expected scheme '%s', got '%s'", recorded.URL.Scheme, got.URL.Scheme)
+			return validationError([]Mismatch{{Field: "scheme", Expected: quoted(recorded.URL.Scheme), Got: quoted(got.URL.Scheme)}})
 		}
 		return nil
 	})
 }
 
 // HeadersValidator validates headers of the request.
-// It is not sensitive to the order of headers.
+// It is not sensitive to the order of headers, but all the values of repeated headers are compared in order.
 // User-Agent and Content-Length are removed from the comparison, because it is added deeper in the http client call.
-func HeadersValidator() RequestValidator {
+// Lookup FieldValidatorOption implementations to ignore or loosely compare the headers, that change on every call, e.g.
+//
+//	HeadersValidator(
+//		WithIgnoredFields("Traceparent"),
+//		WithFieldComparator("X-Request-Id", AnyUUID()),
+//	)
+func HeadersValidator(opts ...FieldValidatorOption) RequestValidator {
+	cfg := newFieldValidatorConfig(http.CanonicalHeaderKey, append([]FieldValidatorOption{WithIgnoredFields("User-Agent", "Content-Length")}, opts...))
 	return RequestValidatorFunc(func(t T, recorded RequestData, got RequestData) error {
-		recordedHeaders := recorded.Headers.Clone()
-		recordedHeaders.Del("User-Agent")
-		recordedHeaders.Del("Content-Length")
-		for key := range recordedHeaders {
-			recordedHeader, gotHeader := recordedHeaders.Get(key), got.Headers.Get(key)
-			if recordedHeader == "SANITIZED" {
-				got.Headers.Del(key)
-				continue
-			}
-			if recordedHeader != gotHeader {
-				t.Errorf("expected header '%s' to be '%s', got '%s'", key, recordedHeader, gotHeader)
-			}
-			got.Headers.Del(key)
-		}
-		for key := range got.Headers {
-			t.Errorf("unexpected header '%s' with value '%s'", key, got.Headers.Get(key))
-		}
-		return nil
+		return validationError(cfg.diff("header", canonicalHeaders(recorded.Headers), canonicalHeaders(got.Headers)))
 	})
 }
+
+// canonicalHeaders returns the headers keyed by canonical names, e.g. in case they were set directly in the map.
+func canonicalHeaders(h http.Header) http.Header {
+	canonical := make(http.Header, len(h))
+	for name, values := range h {
+		key := http.CanonicalHeaderKey(name)
+		canonical[key] = append(canonical[key], values...)
+	}
+	return canonical
+}
@@ -1,8 +1,11 @@
 package hypert
 
 import (
+	"errors"
 	"net/http"
 	"net/url"
+	"reflect"
+	"regexp"
 	"testing"
 )
 
@@ -105,17 +108,176 @@ func TestRequestValidators(t *testing.T) {
 			got:       RequestData{Headers: http.Header{"Key1": []string{}}},
 			expectErr: false,
 		},
+		{
+			name:      "HeadersValidator_SanitizedMissing",
+			validator: HeadersValidator(),
+			recorded:  RequestData{Headers: http.Header{"Key1": []string{"SANITIZED"}, "Key2": []string{"Value2"}}},
+			got:       RequestData{Headers: http.Header{"Key2": []string{"Value2"}}},
+			expectErr: false,
+		},
+		{
+			name:      "HeadersValidator_MultipleValuesMismatch",
+			validator: HeadersValidator(),
+			recorded:  RequestData{Headers: http.Header{"Accept": []string{"text/html", "application/json"}}},
+			got:       RequestData{Headers: http.Header{"Accept": []string{"text/html"}}},
+			expectErr: true,
+		},
+		{
+			name:      "HeadersValidator_IgnoredFields",
+			validator: HeadersValidator(WithIgnoredFields("traceparent"), WithIgnoredFieldPatterns(regexp.MustCompile(`^X-Amzn-`))),
+			recorded:  RequestData{Headers: http.Header{"Traceparent": []string{"00-a-b-01"}, "X-Amzn-Trace-Id": []string{"Root=1"}}},
+			got:       RequestData{Headers: http.Header{"Traceparent": []string{"00-c-d-01"}}},
+			expectErr: false,
+		},
+		{
+			name:      "HeadersValidator_Comparator",
+			validator: HeadersValidator(WithFieldComparator("X-Request-Id", AnyUUID()), WithFieldComparator("X-Timestamp", AnyRFC3339())),
+			recorded:  RequestData{Headers: http.Header{"X-Request-Id": []string{"9b2f4c1e-0c43-4f0e-9a55-3e4b1f8c7d21"}, "X-Timestamp": []string{"2024-04-02T15:04:05Z"}}},
+			got:       RequestData{Headers: http.Header{"X-Request-Id": []string{"1f3a9d2c-7b8e-4c5a-8e6f-2d1b0a9c8e7f"}, "X-Timestamp": []string{"2025-01-01T00:00:00+01:00"}}},
+			expectErr: false,
+		},
+		{
+			name:      "HeadersValidator_ComparatorMismatch",
+			validator: HeadersValidator(WithFieldComparator("X-Request-Id", AnyUUID())),
+			recorded:  RequestData{Headers: http.Header{"X-Request-Id": []string{"9b2f4c1e-0c43-4f0e-9a55-3e4b1f8c7d21"}}},
+			got:       RequestData{Headers: http.Header{"X-Request-Id": []string{"req-1"}}},
+			expectErr: true,
+		},
+		{
+			name:      "HeadersValidator_ComparatorMissing",
+			validator: HeadersValidator(WithFieldComparator("X-Request-Id", AnyUUID())),
+			recorded:  RequestData{Headers: http.Header{"X-Request-Id": []string{"9b2f4c1e-0c43-4f0e-9a55-3e4b1f8c7d21"}}},
+			got:       RequestData{Headers: http.Header{}},
+			expectErr: true,
+		},
+		{
+			name:      "QueryParamsValidator_MultipleValuesMismatch",
+			validator: QueryParamsValidator(),
+			recorded:  RequestData{URL: &url.URL{RawQuery: "id=1&id=2"}},
+			got:       RequestData{URL: &url.URL{RawQuery: "id=1&id=3"}},
+			expectErr: true,
+		},
+		{
+			name:      "QueryParamsValidator_IgnoredFields",
+			validator: QueryParamsValidator(WithIgnoredFields("nonce"), WithFieldComparator("since", AnyRFC3339())),
+			recorded:  RequestData{URL: &url.URL{RawQuery: "nonce=a&since=2024-04-02T15:04:05Z&page=1"}},
+			got:       RequestData{URL: &url.URL{RawQuery: "nonce=b&since=2024-05-02T15:04:05Z&page=1"}},
+			expectErr: false,
+		},
+		{
+			name:      "JSONBodyValidator_Match",
+			validator: JSONBodyValidator(),
+			recorded:  RequestData{BodyBytes: []byte(`{"a": 1, "b": [true, null]}`)},
+			got:       RequestData{BodyBytes: []byte(`{"b":[true,null],"a":1.0}`)},
+			expectErr: false,
+		},
+		{
+			name:      "JSONBodyValidator_Mismatch",
+			validator: JSONBodyValidator(),
+			recorded:  RequestData{BodyBytes: []byte(`{"a": 1, "b": [true]}`)},
+			got:       RequestData{BodyBytes: []byte(`{"a": 1, "b": [false]}`)},
+			expectErr: true,
+		},
+		{
+			name:      "JSONBodyValidator_Sanitized",
+			validator: JSONBodyValidator(),
+			recorded:  RequestData{BodyBytes: []byte(`{"user": "john", "password": "SANITIZED"}`)},
+			got:       RequestData{BodyBytes: []byte(`{"user": "john", "password": {"hash": "abc"}}`)},
+			expectErr: false,
+		},
+		{
+			name:      "JSONBodyValidator_IgnoredPaths",
+			validator: JSONBodyValidator(WithJSONIgnoredPaths("requestId", "items.*.createdAt")),
+			recorded:  RequestData{BodyBytes: []byte(`{"requestId": "a", "items": [{"id": 1, "createdAt": "2024-01-01"}]}`)},
+			got:       RequestData{BodyBytes: []byte(`{"items": [{"id": 1, "createdAt": "2025-02-02"}]}`)},
+			expectErr: false,
+		},
+		{
+			name:      "JSONBodyValidator_IgnoredPathsOtherFieldDiffers",
+			validator: JSONBodyValidator(WithJSONIgnoredPaths("items.*.createdAt")),
+			recorded:  RequestData{BodyBytes: []byte(`{"items": [{"id": 1, "createdAt": "2024-01-01"}]}`)},
+			got:       RequestData{BodyBytes: []byte(`{"items": [{"id": 2, "createdAt": "2025-02-02"}]}`)},
+			expectErr: true,
+		},
+		{
+			name:      "JSONBodyValidator_TrailingData",
+			validator: JSONBodyValidator(),
+			recorded:  RequestData{BodyBytes: []byte(`{"a": 1}`)},
+			got:       RequestData{BodyBytes: []byte(`{"a": 1}garbage`)},
+			expectErr: true,
+		},
+		{
+			name:      "JSONBodyValidator_NotJSON",
+			validator: JSONBodyValidator(),
+			recorded:  RequestData{BodyBytes: []byte(`{}`)},
+			got:       RequestData{BodyBytes: []byte(`a=b`)},
+			expectErr: true,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mT := &mockT{}
-			if err := tc.validator.Validate(mT, tc.recorded, tc.got); err != nil {
-				t.Errorf("request validation failed: %v", err)
+			err := tc.validator.Validate(mT, tc.recorded, tc.got)
+			var validationErr *ValidationError
+			if err != nil && !errors.As(err, &validationErr) {
+				t.Errorf("expected ValidationError, got %v", err)
+			}
+			if tc.expectErr != (err != nil) {
+				t.Errorf("expected error value mismatch. Expected %v, got %v", tc.expectErr, err)
 			}
-			if tc.expectErr != mT.failed {
-				t.Errorf("expected error value mismatch. Expected %v, got %v", tc.expectErr, mT.failed)
+			if mT.failed {
+				t.Errorf("expected validator not to fail the test directly, got %s", mT.msg)
 			}
 		})
 	}
 }
+
+func TestComposedRequestValidator(t *testing.T) {
+	v := ComposedRequestValidator(PathValidator(), MethodValidator(), RequestValidatorFunc(func(t T, recorded, got RequestData) error {
+		t.Errorf("custom validators can still fail the test directly")
+		return nil
+	}))
+	mT := &mockT{}
+	err := v.Validate(mT,
+		RequestData{Method: http.MethodGet, URL: &url.URL{Path: "/foo"}},
+		RequestData{Method: http.MethodPost, URL: &url.URL{Path: "/bar"}},
+	)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	expected := []Mismatch{
+		{Field: "path", Expected: "'/foo'", Got: "'/bar'"},
+		{Field: "method", Expected: "'GET'", Got: "'POST'"},
+	}
+	if !reflect.DeepEqual(validationErr.Mismatches, expected) {
+		t.Errorf("expected mismatches %v, got %v", expected, validationErr.Mismatches)
+	}
+	if !mT.failed {
+		t.Errorf("expected custom validator to fail the test")
+	}
+
+	if err := v.Validate(&mockT{}, RequestData{URL: &url.URL{}}, RequestData{URL: &url.URL{}}); err != nil {
+		t.Errorf("expected no error for matching requests, got %v", err)
+	}
+}
+
+func TestHeadersValidator_Mismatches(t *testing.T) {
+	err := HeadersValidator().Validate(&mockT{},
+		RequestData{Headers: http.Header{"Accept": []string{"text/html", "application/json"}, "X-Old": []string{"a"}}},
+		RequestData{Headers: http.Header{"Accept": []string{"text/html"}, "X-New": []string{"b"}}},
+	)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	expected := []Mismatch{
+		{Field: "header 'Accept'", Expected: "['text/html', 'application/json']", Got: "'text/html'"},
+		{Field: "header 'X-New'", Expected: Missing, Got: "'b'"},
+		{Field: "header 'X-Old'", Expected: "'a'", Got: Missing},
+	}
+	if !reflect.DeepEqual(validationErr.Mismatches, expected) {
+		t.Errorf("expected mismatches %v, got %v", expected, validationErr.Mismatches)
+	}
+}
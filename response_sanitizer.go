@@ -0,0 +1,150 @@
+package hypert
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ResponseSanitizer ensures, that no sensitive data is written to the response records.
+// The sanitized version would be stored, whilst the original one would be returned to the client in the record mode.
+// It is allowed to mutate the response in place, because it is a copy of the original one.
+type ResponseSanitizer interface {
+	SanitizeResponse(resp *http.Response) *http.Response
+}
+
+// DefaultResponseSanitizer returns a ResponseSanitizer that sanitizes the most common headers carrying credentials.
+// The bodies are not sanitized by default, as their structure is API-specific;
+// lookup ResponseJSONFieldsSanitizer and ResponseBodyRegexSanitizer for that.
+func DefaultResponseSanitizer() ResponseSanitizer {
+	return DefaultResponseHeadersSanitizer()
+}
+
+// ResponseSanitizerFunc is a helper type for a function that implements ResponseSanitizer interface.
+type ResponseSanitizerFunc func(resp *http.Response) *http.Response
+
+func (f ResponseSanitizerFunc) SanitizeResponse(resp *http.Response) *http.Response {
+	return f(resp)
+}
+
+// ComposedResponseSanitizer is a sanitizer that sequentially runs passed sanitizers.
+func ComposedResponseSanitizer(s ...ResponseSanitizer) ResponseSanitizer {
+	return ResponseSanitizerFunc(func(resp *http.Response) *http.Response {
+		for _, s := range s {
+			resp = s.SanitizeResponse(resp)
+		}
+		return resp
+	})
+}
+
+// ResponseHeadersSanitizer sets listed response headers to "SANITIZED".
+// Set-Cookie headers are handled in a special way: cookie names and attributes are kept, and only the values are sanitized,
+// so that the client can still find the cookies it expects.
+// Lookup DefaultResponseHeadersSanitizer for a default value.
+func ResponseHeadersSanitizer(headers ...string) ResponseSanitizer {
+	return responseHeadersSanitizer(sanitizeString, headers)
+}
+
+func responseHeadersSanitizer(replace func(string) string, headers []string) ResponseSanitizer {
+	return ResponseSanitizerFunc(func(resp *http.Response) *http.Response {
+		for _, header := range headers {
+			values := resp.Header.Values(header)
+			if len(values) == 0 {
+				continue
+			}
+			sanitized := make([]string, 0, len(values))
+			for _, v := range values {
+				if http.CanonicalHeaderKey(header) == "Set-Cookie" {
+					sanitized = append(sanitized, sanitizeSetCookie(v, replace))
+				} else {
+					sanitized = append(sanitized, replace(v))
+				}
+			}
+			resp.Header[http.CanonicalHeaderKey(header)] = sanitized
+		}
+		return resp
+	})
+}
+
+// sanitizeSetCookie replaces the value of "name=value; attributes" cookie.
+func sanitizeSetCookie(v string, replace func(string) string) string {
+	pair, attrs, hasAttrs := strings.Cut(v, ";")
+	name, value, ok := strings.Cut(pair, "=")
+	if !ok {
+		return replace(v)
+	}
+	sanitized := strings.TrimSpace(name) + "=" + replace(strings.TrimSpace(value))
+	if hasAttrs {
+		sanitized += ";" + attrs
+	}
+	return sanitized
+}
+
+// DefaultResponseHeadersSanitizer is ResponseHeadersSanitizer with the most common response headers that should be sanitized in most cases.
+func DefaultResponseHeadersSanitizer() ResponseSanitizer {
+	return ResponseHeadersSanitizer(
+		"Set-Cookie",
+		"WWW-Authenticate",
+		"Proxy-Authenticate",
+		"Authentication-Info",
+		"X-Auth-Token",
+		"X-Access-Token",
+		"X-API-Key",
+		"X-Amz-Security-Token",
+	)
+}
+
+// ResponseJSONFieldsSanitizer sets the values at listed JSON paths of the response body to "SANITIZED".
+// Paths are dot-separated, e.g. "user.email", and "*" matches any object key or array element, e.g. "accounts.*.id".
+// Responses with bodies that are not valid JSON, or are encoded (e.g. gzipped), are stored as they are.
+func ResponseJSONFieldsSanitizer(paths ...string) ResponseSanitizer {
+	return responseJSONFieldsSanitizer(sanitizeJSONValue, paths)
+}
+
+func responseJSONFieldsSanitizer(replace func(any) any, paths []string) ResponseSanitizer {
+	parsed := parseJSONPaths(paths)
+	return responseBodySanitizer(func(body []byte) []byte {
+		sanitized, _ := replaceJSONPaths(body, parsed, replace)
+		return sanitized
+	})
+}
+
+// ResponseBodyRegexSanitizer replaces all the matches of the regular expressions in the response body with "SANITIZED".
+// Encoded (e.g. gzipped) bodies are stored as they are.
+func ResponseBodyRegexSanitizer(patterns ...*regexp.Regexp) ResponseSanitizer {
+	return responseBodySanitizer(func(body []byte) []byte {
+		for _, re := range patterns {
+			body = re.ReplaceAllLiteral(body, []byte(sanitizedValue))
+		}
+		return body
+	})
+}
+
+// responseBodySanitizer reads the whole response body, runs sanitize on it and updates the content length.
+func responseBodySanitizer(sanitize func(body []byte) []byte) ResponseSanitizer {
+	return ResponseSanitizerFunc(func(resp *http.Response) *http.Response {
+		if resp.Body == nil || resp.Header.Get("Content-Encoding") != "" {
+			return resp
+		}
+		if spool, streamed := spooledBodyOf(resp.Body); streamed {
+			spool.unsanitized = true
+			return resp
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			return resp
+		}
+		body = sanitize(body)
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		resp.ContentLength = int64(len(body))
+		if resp.Header.Get("Content-Length") != "" {
+			resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		return resp
+	})
+}
@@ -0,0 +1,88 @@
+package hypert
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+func newTestResponse(body string) *http.Response {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+func TestResponseHeadersSanitizer(t *testing.T) {
+	resp := newTestResponse("")
+	resp.Header.Add("Set-Cookie", "session=abc; Path=/; HttpOnly")
+	resp.Header.Add("Set-Cookie", "csrf=def")
+	resp.Header.Set("WWW-Authenticate", `Bearer realm="api", token="secret"`)
+
+	sanitized := DefaultResponseSanitizer().SanitizeResponse(resp) //nolint:bodyclose // body is not read
+
+	cookies := sanitized.Header.Values("Set-Cookie")
+	if len(cookies) != 2 || cookies[0] != "session=SANITIZED; Path=/; HttpOnly" || cookies[1] != "csrf=SANITIZED" {
+		t.Errorf("unexpected sanitized cookies: %v", cookies)
+	}
+	if got := sanitized.Header.Get("WWW-Authenticate"); got != "SANITIZED" {
+		t.Errorf("expected SANITIZED, got %s", got)
+	}
+	if got := sanitized.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("expected Content-Type to be kept, got %s", got)
+	}
+}
+
+func TestResponseBodySanitizers(t *testing.T) {
+	testCases := []struct {
+		name      string
+		sanitizer ResponseSanitizer
+		body      string
+		expected  string
+	}{
+		{
+			name:      "json fields",
+			sanitizer: ResponseJSONFieldsSanitizer("user.email", "accounts.*.id"),
+			body:      `{"accounts":[{"id":1,"name":"a"},{"id":2,"name":"b"}],"user":{"email":"a@b.c","age":30}}`,
+			expected:  `{"accounts":[{"id":"SANITIZED","name":"a"},{"id":"SANITIZED","name":"b"}],"user":{"age":30,"email":"SANITIZED"}}`,
+		},
+		{
+			name:      "json fields with array index",
+			sanitizer: ResponseJSONFieldsSanitizer("$.tokens[0]"),
+			body:      `{"tokens":["a","b"]}`,
+			expected:  `{"tokens":["SANITIZED","b"]}`,
+		},
+		{
+			name:      "not a json",
+			sanitizer: ResponseJSONFieldsSanitizer("token"),
+			body:      `token=abc`,
+			expected:  `token=abc`,
+		},
+		{
+			name:      "regex",
+			sanitizer: ResponseBodyRegexSanitizer(regexp.MustCompile(`[a-z]+@example\.com`)),
+			body:      `contact: john@example.com, jane@example.com`,
+			expected:  `contact: SANITIZED, SANITIZED`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sanitized := tc.sanitizer.SanitizeResponse(newTestResponse(tc.body))
+			defer sanitized.Body.Close()
+			body, err := io.ReadAll(sanitized.Body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+			if string(body) != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, body)
+			}
+			if sanitized.ContentLength != int64(len(body)) {
+				t.Errorf("expected content length %d, got %d", len(body), sanitized.ContentLength)
+			}
+		})
+	}
+}
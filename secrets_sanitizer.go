@@ -0,0 +1,108 @@
+package hypert
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SecretsSanitizer replaces every occurrence of the listed values in the stored request's URL, headers and body with "SANITIZED".
+// It's meant for the secrets, that must never appear in the recordings regardless of where they are sent, e.g.
+//
+//	SecretsSanitizer(os.Getenv("API_KEY"), os.Getenv("API_SECRET"))
+//
+// Empty values are ignored, so that it's safe to use with environment variables that are unset in replay mode.
+// The URL-escaped forms of the values are replaced as well.
+func SecretsSanitizer(secrets ...string) RequestSanitizer {
+	var oldnew []string
+	seen := make(map[string]struct{})
+	for _, s := range secrets {
+		for _, variant := range []string{s, url.QueryEscape(s), url.PathEscape(s)} {
+			if _, ok := seen[variant]; ok || variant == "" {
+				continue
+			}
+			seen[variant] = struct{}{}
+			oldnew = append(oldnew, variant)
+		}
+	}
+	// longer values go first, so that a secret containing another one is replaced as a whole
+	sort.SliceStable(oldnew, func(i, j int) bool { return len(oldnew[i]) > len(oldnew[j]) })
+	pairs := make([]string, 0, 2*len(oldnew))
+	for _, s := range oldnew {
+		pairs = append(pairs, s, sanitizedValue)
+	}
+	replacer := strings.NewReplacer(pairs...)
+	return redactingSanitizer(func(s string) string {
+		if len(pairs) == 0 {
+			return s
+		}
+		return replacer.Replace(s)
+	})
+}
+
+// RegexSanitizer replaces every match of the regular expressions in the stored request's URL, headers and body with "SANITIZED".
+func RegexSanitizer(patterns ...*regexp.Regexp) RequestSanitizer {
+	return redactingSanitizer(func(s string) string {
+		for _, re := range patterns {
+			s = re.ReplaceAllLiteralString(s, sanitizedValue)
+		}
+		return s
+	})
+}
+
+// redactingSanitizer runs redact on the request's URL, header values and body.
+func redactingSanitizer(redact func(s string) string) RequestSanitizer {
+	return ComposedRequestSanitizer(
+		RequestSanitizerFunc(func(req *http.Request) *http.Request {
+			if req.URL != nil {
+				req.URL = redactURL(req.URL, redact)
+			}
+			req.Host = redactHost(req.Host, redact)
+			for _, values := range req.Header {
+				for i, v := range values {
+					values[i] = redact(v)
+				}
+			}
+			return req
+		}),
+		requestBodySanitizer(func(_ *http.Request, body []byte) []byte {
+			return []byte(redact(string(body)))
+		}),
+	)
+}
+
+// redactURL runs redact on the whole URL, so that the secrets spanning its parts are redacted as well.
+// If the redacted URL can't be parsed, e.g. because the port was redacted, the parts are redacted separately instead,
+// so that the secrets are never kept. The port is kept then, so that the stored request can be replayed.
+func redactURL(u *url.URL, redact func(s string) string) *url.URL {
+	if redacted, err := url.Parse(redact(u.String())); err == nil {
+		return redacted
+	}
+	redacted := *u
+	redacted.Opaque = redact(u.Opaque)
+	redacted.Host = redactHost(u.Host, redact)
+	redacted.Path = redact(u.Path)
+	redacted.RawPath = redact(u.RawPath)
+	redacted.RawQuery = redact(u.RawQuery)
+	redacted.Fragment = redact(u.Fragment)
+	redacted.RawFragment = redact(u.RawFragment)
+	if u.User != nil {
+		redacted.User = url.User(redact(u.User.Username()))
+		if password, ok := u.User.Password(); ok {
+			redacted.User = url.UserPassword(redact(u.User.Username()), redact(password))
+		}
+	}
+	return &redacted
+}
+
+// redactHost runs redact on the host name only, so that the port stays valid.
+func redactHost(host string, redact func(s string) string) string {
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return redact(host)
+	}
+	return net.JoinHostPort(redact(name), port)
+}
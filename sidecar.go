@@ -0,0 +1,197 @@
+package hypert
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// WithSidecarBodies makes TestClient store the request and response bodies in sidecar files, instead of the .http files.
+// The .http files keep the head with X-Hypert-Body-* headers pointing to the sidecar file,
+// and the sidecar file's extension is chosen based on Content-Type header, e.g. 0.resp.body.json or 0.resp.body.png,
+// so that the recordings are easy to review and open with other tools.
+//
+// JSON bodies are pretty-printed, and compacted back on replay, so that the client gets exactly the recorded bytes.
+// It's done only for the compact documents, as the other ones can't be reassembled byte by byte. The pretty-printed files can be edited.
+//
+// The store has to implement BodyStore interface, which FileStore, MemoryStore and FSStore do.
+func WithSidecarBodies() Option {
+	return func(cfg *config) {
+		cfg.sidecarBodies = true
+	}
+}
+
+const (
+	// sidecarFormatJSON means the compact JSON body was pretty-printed.
+	sidecarFormatJSON = "json"
+	// sidecarFormatJSONNewline works like sidecarFormatJSON, for the compact JSON body followed by a newline, e.g. written by json.Encoder.
+	sidecarFormatJSONNewline = "json-newline"
+)
+
+// sidecarExtensions are the extensions of the common media types. They take precedence over mime.ExtensionsByType,
+// which results depend on the system's configuration.
+var sidecarExtensions = map[string]string{
+	"application/json":                  ".json",
+	"application/xml":                   ".xml",
+	"text/xml":                          ".xml",
+	"text/html":                         ".html",
+	"text/plain":                        ".txt",
+	"text/csv":                          ".csv",
+	"text/css":                          ".css",
+	"text/javascript":                   ".js",
+	"application/javascript":            ".js",
+	"application/x-www-form-urlencoded": ".txt",
+	"application/pdf":                   ".pdf",
+	"application/zip":                   ".zip",
+	"application/gzip":                  ".gz",
+	"image/png":                         ".png",
+	"image/jpeg":                        ".jpg",
+	"image/gif":                         ".gif",
+	"image/webp":                        ".webp",
+	"image/svg+xml":                     ".svg",
+	"application/octet-stream":          "",
+}
+
+// sidecarExtension returns the extension of the sidecar file for the body with given headers, or "" if it's unknown.
+// Encoded bodies, e.g. gzipped ones, get the extension of the encoding.
+func sidecarExtension(h http.Header) string {
+	switch strings.ToLower(h.Get("Content-Encoding")) {
+	case "":
+	case "gzip":
+		return ".gz"
+	default:
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	if ext, ok := sidecarExtensions[mediaType]; ok {
+		return ext
+	}
+	switch {
+	case strings.HasSuffix(mediaType, "+json"):
+		return ".json"
+	case strings.HasSuffix(mediaType, "+xml"):
+		return ".xml"
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) == 1 {
+		return exts[0]
+	}
+	return ""
+}
+
+// encodeSidecarBody returns the content of the sidecar file for the body with given headers, and its sidecarFormat*, if it was transformed.
+func encodeSidecarBody(h http.Header, body []byte) (content []byte, format string) {
+	if h.Get("Content-Encoding") != "" || sidecarExtension(h) != ".json" {
+		return body, ""
+	}
+	document, format := body, sidecarFormatJSON
+	if trimmed := bytes.TrimSuffix(body, []byte("\n")); len(trimmed) < len(body) {
+		document, format = trimmed, sidecarFormatJSONNewline
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, document); err != nil || !bytes.Equal(compact.Bytes(), document) {
+		return body, ""
+	}
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, document, "", "  "); err != nil {
+		return body, ""
+	}
+	pretty.WriteByte('\n')
+	return pretty.Bytes(), format
+}
+
+// decodeSidecarBody reverses encodeSidecarBody.
+func decodeSidecarBody(format string, content []byte) ([]byte, error) {
+	switch format {
+	case "":
+		return content, nil
+	case sidecarFormatJSON, sidecarFormatJSONNewline:
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, content); err != nil {
+			return nil, fmt.Errorf("compact JSON body: %w", err)
+		}
+		if format == sidecarFormatJSONNewline {
+			compact.WriteByte('\n')
+		}
+		return compact.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown body format '%s'", format)
+	}
+}
+
+// splitRequestDump splits the dump of the request into the head pointing to the sidecar file, and the sidecar file's content.
+// ok is false, if the request has no body, and it should be stored as it is.
+func splitRequestDump(name string, dump []byte) (head []byte, ref bodyFileRef, content []byte, ok bool, err error) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(dump)))
+	if err != nil {
+		return nil, bodyFileRef{}, nil, false, err
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil || len(body) == 0 {
+		return nil, bodyFileRef{}, nil, false, err
+	}
+	ref, content = sidecarRef(name, req.Header, body)
+	setBodyFileRef(req.Header, ref)
+	var buf bytes.Buffer
+	if err := writeRequestHead(&buf, req); err != nil {
+		return nil, bodyFileRef{}, nil, false, err
+	}
+	return buf.Bytes(), ref, content, true, nil
+}
+
+// splitResponseDump works like splitRequestDump, but for the response.
+func splitResponseDump(name string, dump []byte, req *http.Request) (head []byte, ref bodyFileRef, content []byte, ok bool, err error) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), req)
+	if err != nil {
+		return nil, bodyFileRef{}, nil, false, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || len(body) == 0 {
+		return nil, bodyFileRef{}, nil, false, err
+	}
+	ref, content = sidecarRef(name, resp.Header, body)
+	setBodyFileRef(resp.Header, ref)
+	var buf bytes.Buffer
+	if err := writeResponseHead(&buf, resp); err != nil {
+		return nil, bodyFileRef{}, nil, false, err
+	}
+	return buf.Bytes(), ref, content, true, nil
+}
+
+func sidecarRef(name string, h http.Header, body []byte) (bodyFileRef, []byte) {
+	content, format := encodeSidecarBody(h, body)
+	digest := sha256.Sum256(body)
+	return bodyFileRef{
+		name:   bodyFileName(name, sidecarExtension(h)),
+		size:   int64(len(body)),
+		sha256: hex.EncodeToString(digest[:]),
+		format: format,
+	}, content
+}
+
+// readSidecarBody reads the body from the sidecar file, reversing the transformations made before it was stored.
+func readSidecarBody(s Store, ref bodyFileRef) ([]byte, error) {
+	bs, err := bodyStore(s)
+	if err != nil {
+		return nil, err
+	}
+	f, err := bs.OpenBody(ref.name)
+	if err != nil {
+		return nil, fmt.Errorf("open body %s: %w", ref.name, err)
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("read body %s: %w", ref.name, err)
+	}
+	return decodeSidecarBody(ref.format, content)
+}
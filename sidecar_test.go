@@ -0,0 +1,151 @@
+package hypert
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSidecarBodies_RecordAndReplay(t *testing.T) {
+	const respBody = `{"id":1,"tags":["a","b"]}` + "\n"
+	png := []byte("\x89PNG\r\n\x1a\nnot really an image")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/items":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, respBody)
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(png)
+		}
+	}))
+	defer srv.Close()
+
+	store := NewMemoryStore()
+	run := func(t *testing.T, mode Mode) *mockT {
+		mT := &mockT{}
+		client := TestClient(t, false,
+			WithMode(mode),
+			WithStore(store),
+			WithNamingScheme(&SequentialNamingScheme{dir: "sidecar"}),
+			WithSidecarBodies(),
+			WithRequestValidator(ComposedRequestValidator(DefaultRequestValidator(), BodyValidator())),
+		)
+		if mode == ModeReplay {
+			client.Transport.(*replayTransport).t = mT
+		}
+
+		resp, err := client.Post(srv.URL+"/items", "application/json", strings.NewReader(`{"name":"item"}`))
+		if err != nil {
+			t.Fatalf("failed to create item: %v", err)
+		}
+		got, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		if string(got) != respBody || resp.ContentLength != int64(len(respBody)) {
+			t.Errorf("expected exactly the recorded body %q, got %q with length %d", respBody, got, resp.ContentLength)
+		}
+
+		resp, err = client.Get(srv.URL + "/image")
+		if err != nil {
+			t.Fatalf("failed to get image: %v", err)
+		}
+		got, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		if !bytes.Equal(got, png) {
+			t.Errorf("expected exactly the recorded image, got %q", got)
+		}
+		return mT
+	}
+
+	t.Run("record", func(t *testing.T) {
+		run(t, ModeRecord)
+		expectedFiles := map[string]string{
+			"sidecar/0.req.body.json":  "{\n  \"name\": \"item\"\n}\n",
+			"sidecar/0.resp.body.json": "{\n  \"id\": 1,\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ]\n}\n",
+			"sidecar/1.resp.body.png":  string(png),
+		}
+		for name, expected := range expectedFiles {
+			if got := readStoreContent(t, store.OpenBody, name); got != expected {
+				t.Errorf("expected %s to contain:\n%s\ngot:\n%s", name, expected, got)
+			}
+		}
+		respHead := readStoreContent(t, store.OpenResponse, "sidecar/0.resp.http")
+		for _, expected := range []string{"X-Hypert-Body-File: 0.resp.body.json", "X-Hypert-Body-Format: json-newline"} {
+			if !strings.Contains(respHead, expected) {
+				t.Errorf("expected response head to contain %q, got:\n%s", expected, respHead)
+			}
+		}
+		if strings.Contains(respHead, `"id"`) {
+			t.Errorf("expected response head without body, got:\n%s", respHead)
+		}
+		if _, err := store.OpenRequest("sidecar/1.req.http"); err != nil {
+			t.Errorf("expected request without body to be stored: %v", err)
+		}
+	})
+	t.Run("replay", func(t *testing.T) {
+		if mT := run(t, ModeReplay); mT.failed {
+			t.Errorf("expected the requests to match the recorded ones, got %s", mT.msg)
+		}
+	})
+}
+
+func TestEncodeSidecarBody(t *testing.T) {
+	testCases := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedFormat string
+	}{
+		{name: "compact JSON", contentType: "application/json", body: `{"a":[1,2]}`, expectedFormat: sidecarFormatJSON},
+		{name: "compact JSON with newline", contentType: "application/problem+json", body: "{\"a\":1}\n", expectedFormat: sidecarFormatJSONNewline},
+		{name: "indented JSON", contentType: "application/json", body: "{\n\t\"a\": 1\n}", expectedFormat: ""},
+		{name: "invalid JSON", contentType: "application/json", body: `{"a":`, expectedFormat: ""},
+		{name: "not JSON", contentType: "text/plain", body: `{"a":1}`, expectedFormat: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content, format := encodeSidecarBody(http.Header{"Content-Type": []string{tc.contentType}}, []byte(tc.body))
+			if format != tc.expectedFormat {
+				t.Errorf("expected format %q, got %q", tc.expectedFormat, format)
+			}
+			decoded, err := decodeSidecarBody(format, content)
+			if err != nil {
+				t.Fatalf("failed to decode: %v", err)
+			}
+			if string(decoded) != tc.body {
+				t.Errorf("expected body to be reassembled exactly, got %q", decoded)
+			}
+		})
+	}
+}
+
+func TestSidecarExtension(t *testing.T) {
+	testCases := []struct {
+		header   http.Header
+		expected string
+	}{
+		{header: http.Header{"Content-Type": []string{"application/json; charset=utf-8"}}, expected: ".json"},
+		{header: http.Header{"Content-Type": []string{"application/vnd.api+json"}}, expected: ".json"},
+		{header: http.Header{"Content-Type": []string{"image/svg+xml"}}, expected: ".svg"},
+		{header: http.Header{"Content-Type": []string{"application/atom+xml"}}, expected: ".xml"},
+		{header: http.Header{"Content-Type": []string{"image/jpeg"}}, expected: ".jpg"},
+		{header: http.Header{"Content-Type": []string{"application/octet-stream"}}, expected: ""},
+		{header: http.Header{}, expected: ""},
+		{header: http.Header{"Content-Type": []string{"application/json"}, "Content-Encoding": []string{"gzip"}}, expected: ".gz"},
+		{header: http.Header{"Content-Type": []string{"application/json"}, "Content-Encoding": []string{"br"}}, expected: ""},
+	}
+	for _, tc := range testCases {
+		if got := sidecarExtension(tc.header); got != tc.expected {
+			t.Errorf("expected extension %q for %v, got %q", tc.expected, tc.header, got)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package hypert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Store is where the recorded requests and responses are kept.
+// By default, TestClient uses FileStore, which keeps each of them in a separate file.
+// Use WithStore option to plug in a different implementation.
+//
+// The names passed to its methods are the ones returned by the NamingScheme.
+// Open methods should return an error wrapping os.ErrNotExist, if there is no recording with given name.
+//
+// The methods should be safe for concurrent use.
+type Store interface {
+	WriteRequest(name string, r io.Reader) error
+	WriteResponse(name string, r io.Reader) error
+	OpenRequest(name string) (io.ReadCloser, error)
+	OpenResponse(name string) (io.ReadCloser, error)
+	// List returns all the recordings kept in the store, in the order they should be considered during replay.
+	// The returned names should be accepted by Open methods.
+	// Stores, that are not able to list recordings, should return an error wrapping ErrListNotSupported.
+	List() ([]Recording, error)
+}
+
+// Recording holds the names of the stored request and response pair.
+type Recording struct {
+	Request  string
+	Response string
+}
+
+// ErrListNotSupported is returned by Store's List method, if the store is not able to list the recordings.
+var ErrListNotSupported = errors.New("listing recordings is not supported")
+
+// WithStore sets the Store, in which the recorded requests and responses are kept.
+// By default, FileStore in the naming scheme's directory is used.
+func WithStore(s Store) Option {
+	return func(cfg *config) {
+		cfg.store = s
+	}
+}
+
+// recordingPair returns the Recording for given request name, following <name>.req.http, <name>.resp.http convention.
+// ok is false, if the name doesn't follow the convention.
+func recordingPair(reqName string) (r Recording, ok bool) {
+	if !strings.HasSuffix(reqName, ".req.http") {
+		return Recording{}, false
+	}
+	return Recording{
+		Request:  reqName,
+		Response: strings.TrimSuffix(reqName, ".req.http") + ".resp.http",
+	}, true
+}
+
+// sortRecordings sorts the recordings listed from a directory by request names, so that e.g. 2.req.http goes before 10.req.http.
+func sortRecordings(recordings []Recording) {
+	sort.SliceStable(recordings, func(i, j int) bool {
+		return naturalLess(recordings[i].Request, recordings[j].Request)
+	})
+}
+
+// naturalLess compares the names, treating the runs of digits as numbers.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		digitsA, digitsB := leadingDigits(a), leadingDigits(b)
+		if digitsA != "" && digitsB != "" {
+			numA, numB := strings.TrimLeft(digitsA, "0"), strings.TrimLeft(digitsB, "0")
+			if len(numA) != len(numB) {
+				return len(numA) < len(numB)
+			}
+			if numA != numB {
+				return numA < numB
+			}
+			a, b = a[len(digitsA):], b[len(digitsB):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+// FileStore keeps each request and response in a separate file, named exactly as NamingScheme returned.
+// It should be initialized using NewFileStore function.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore initializes FileStore, that implements Store interface.
+// 'dir' parameter indicates, in which directory the recordings are listed. It should be the same as the naming scheme's directory.
+// If it's empty, listing recordings is not supported.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func (s *FileStore) WriteRequest(name string, r io.Reader) error {
+	return writeFile(name, r)
+}
+
+func (s *FileStore) WriteResponse(name string, r io.Reader) error {
+	return writeFile(name, r)
+}
+
+func (s *FileStore) OpenRequest(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (s *FileStore) OpenResponse(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (s *FileStore) WriteBody(name string, r io.Reader) error {
+	return writeFile(name, r)
+}
+
+func (s *FileStore) OpenBody(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+// List returns the recordings following <name>.req.http, <name>.resp.http convention from the store's directory.
+// They are sorted by name, with the numbers compared numerically, so that the sequentially named recordings are in the order of recording.
+func (s *FileStore) List() ([]Recording, error) {
+	if s.dir == "" {
+		return nil, fmt.Errorf("file store without directory: %w", ErrListNotSupported)
+	}
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read directory %s: %w", s.dir, err)
+	}
+	var recordings []Recording
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if r, ok := recordingPair(filepath.Join(s.dir, entry.Name())); ok {
+			recordings = append(recordings, r)
+		}
+	}
+	sortRecordings(recordings)
+	return recordings, nil
+}
+
+func writeFile(name string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o760); err != nil {
+		return fmt.Errorf("create directory for %s: %w", name, err)
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("open file %s: %w", name, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("write file %s: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close file %s: %w", name, err)
+	}
+	return nil
+}
+
+// MemoryStore keeps the recordings in memory. It's useful for unit tests of hypert's extensions.
+// It should be initialized using NewMemoryStore function.
+type MemoryStore struct {
+	mu       sync.Mutex
+	contents map[string][]byte
+	order    []string
+}
+
+// NewMemoryStore initializes empty MemoryStore, that implements Store interface.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{contents: make(map[string][]byte)}
+}
+
+func (s *MemoryStore) WriteRequest(name string, r io.Reader) error {
+	return s.write(name, r)
+}
+
+func (s *MemoryStore) WriteResponse(name string, r io.Reader) error {
+	return s.write(name, r)
+}
+
+func (s *MemoryStore) OpenRequest(name string) (io.ReadCloser, error) {
+	return s.open(name)
+}
+
+func (s *MemoryStore) OpenResponse(name string) (io.ReadCloser, error) {
+	return s.open(name)
+}
+
+func (s *MemoryStore) WriteBody(name string, r io.Reader) error {
+	return s.write(name, r)
+}
+
+func (s *MemoryStore) OpenBody(name string) (io.ReadCloser, error) {
+	return s.open(name)
+}
+
+// List returns the recordings following <name>.req.http, <name>.resp.http convention in the order they were written.
+func (s *MemoryStore) List() ([]Recording, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var recordings []Recording
+	for _, name := range s.order {
+		if r, ok := recordingPair(name); ok {
+			recordings = append(recordings, r)
+		}
+	}
+	return recordings, nil
+}
+
+func (s *MemoryStore) write(name string, r io.Reader) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.contents[name]; !ok {
+		s.order = append(s.order, name)
+	}
+	s.contents[name] = content
+	return nil
+}
+
+func (s *MemoryStore) open(name string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.contents[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+// ErrReadOnlyStore is returned by the store created with ReadOnlyStore, when a write is attempted.
+var ErrReadOnlyStore = errors.New("store is read-only")
+
+// ReadOnlyStore wraps the Store, so that all the writes fail with ErrReadOnlyStore.
+// It protects the recordings from being overwritten, e.g. when the mode is accidentally set to record.
+func ReadOnlyStore(s Store) Store {
+	return readOnlyStore{Store: s}
+}
+
+type readOnlyStore struct {
+	Store
+}
+
+func (s readOnlyStore) WriteRequest(name string, _ io.Reader) error {
+	return fmt.Errorf("write request %s: %w", name, ErrReadOnlyStore)
+}
+
+func (s readOnlyStore) WriteResponse(name string, _ io.Reader) error {
+	return fmt.Errorf("write response %s: %w", name, ErrReadOnlyStore)
+}
+
+func (s readOnlyStore) WriteBody(name string, _ io.Reader) error {
+	return fmt.Errorf("write body %s: %w", name, ErrReadOnlyStore)
+}
+
+func (s readOnlyStore) OpenBody(name string) (io.ReadCloser, error) {
+	bs, err := bodyStore(s.Store)
+	if err != nil {
+		return nil, err
+	}
+	return bs.OpenBody(name)
+}
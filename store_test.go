@@ -0,0 +1,112 @@
+package hypert
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileStore_List(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"0.req.http", "0.resp.http", "10.req.http", "10.resp.http", "2.req.http", "2.resp.http", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	got, err := NewFileStore(dir).List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Recording{
+		{Request: filepath.Join(dir, "0.req.http"), Response: filepath.Join(dir, "0.resp.http")},
+		{Request: filepath.Join(dir, "2.req.http"), Response: filepath.Join(dir, "2.resp.http")},
+		{Request: filepath.Join(dir, "10.req.http"), Response: filepath.Join(dir, "10.resp.http")},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	if _, err := NewFileStore("").List(); !errors.Is(err, ErrListNotSupported) {
+		t.Errorf("expected ErrListNotSupported, got %v", err)
+	}
+}
+
+func TestMemoryStore_RecordAndReplay(t *testing.T) {
+	store := NewMemoryStore()
+	mockRT := &mockRoundTripper{
+		resp: &http.Response{ //nolint:bodyclose // Response body is closed after the round trip
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString("from memory")),
+		},
+	}
+	record := &recordTransport{
+		httpTransport: mockRT,
+		namingScheme:  &SequentialNamingScheme{dir: "mem"},
+		sanitizer:     NoOpRequestSanitizer{},
+		store:         store,
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	resp, err := record.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+	resp.Body.Close()
+
+	recordings, err := store.List()
+	if err != nil {
+		t.Fatalf("failed to list recordings: %v", err)
+	}
+	if len(recordings) != 1 || recordings[0].Request != "mem/0.req.http" || recordings[0].Response != "mem/0.resp.http" {
+		t.Errorf("unexpected recordings: %v", recordings)
+	}
+
+	replay := &replayTransport{
+		t:         t,
+		scheme:    &SequentialNamingScheme{dir: "mem"},
+		sanitizer: NoOpRequestSanitizer{},
+		validator: DefaultRequestValidator(),
+		store:     store,
+	}
+	resp, err = replay.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("failed to replay: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if string(body) != "from memory" {
+		t.Errorf("expected replayed body %q, got %q", "from memory", body)
+	}
+}
+
+func TestReadOnlyStore(t *testing.T) {
+	memory := NewMemoryStore()
+	if err := memory.WriteRequest("0.req.http", strings.NewReader("content")); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	store := ReadOnlyStore(memory)
+
+	if err := store.WriteRequest("1.req.http", strings.NewReader("content")); !errors.Is(err, ErrReadOnlyStore) {
+		t.Errorf("expected ErrReadOnlyStore, got %v", err)
+	}
+	if err := store.WriteResponse("1.resp.http", strings.NewReader("content")); !errors.Is(err, ErrReadOnlyStore) {
+		t.Errorf("expected ErrReadOnlyStore, got %v", err)
+	}
+	f, err := store.OpenRequest("0.req.http")
+	if err != nil {
+		t.Fatalf("expected read to succeed, got %v", err)
+	}
+	f.Close()
+}
@@ -0,0 +1,110 @@
+package hypert
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// UnusedRecordingsCheck defines, how the recordings that were not used during the test are reported.
+type UnusedRecordingsCheck int
+
+const (
+	// UnusedRecordingsOff disables the check. Default value.
+	UnusedRecordingsOff UnusedRecordingsCheck = iota
+	// UnusedRecordingsWarn logs the unused recordings.
+	UnusedRecordingsWarn
+	// UnusedRecordingsError fails the test, if any recording was not used.
+	UnusedRecordingsError
+)
+
+// WithUnusedRecordingsCheck enables reporting of the recordings, that were not used by the test in replay and hybrid modes.
+// It helps to detect stale recordings and tests, that stopped making some of the requests.
+// The recordings are looked up using Store's List method, so the store needs to support listing.
+// The check is run in test cleanup, so T needs to implement Cleanup(func()) method, as testing.T does.
+func WithUnusedRecordingsCheck(check UnusedRecordingsCheck) Option {
+	return func(cfg *config) {
+		cfg.unusedRecordingsCheck = check
+	}
+}
+
+// usedRecordings tracks the request files, that were used during the test.
+// The files are compared by their base names, because the store might list them relatively, e.g. in case of cassettes.
+// Its methods are safe to call on nil value, which doesn't track anything.
+type usedRecordings struct {
+	mu    sync.Mutex
+	files map[string]struct{}
+}
+
+func newUsedRecordings() *usedRecordings {
+	return &usedRecordings{files: make(map[string]struct{})}
+}
+
+func (u *usedRecordings) markUsed(reqFile string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.files[filepath.Base(reqFile)] = struct{}{}
+}
+
+func (u *usedRecordings) isUsed(reqFile string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	_, ok := u.files[filepath.Base(reqFile)]
+	return ok
+}
+
+// registerUnusedRecordingsCheck registers a cleanup function, that reports recordings, that weren't used during the test.
+func registerUnusedRecordingsCheck(t T, check UnusedRecordingsCheck, store Store, used *usedRecordings) {
+	t.Helper()
+	if check == UnusedRecordingsOff {
+		return
+	}
+	cleanuper, ok := t.(interface{ Cleanup(func()) })
+	if !ok {
+		t.Log("hypert: T doesn't implement Cleanup method, unused recordings won't be reported")
+		return
+	}
+	cleanuper.Cleanup(func() {
+		unused, err := findUnusedRecordings(store, used)
+		if errors.Is(err, ErrListNotSupported) {
+			t.Logf("hypert: unused recordings won't be reported: %s", err.Error())
+			return
+		}
+		if err != nil {
+			t.Errorf("hypert: find unused recordings: %s", err.Error())
+			return
+		}
+		if len(unused) == 0 {
+			return
+		}
+		msg := fmt.Sprintf("hypert: %d recording(s) were not used during the test:\n\t%s", len(unused), strings.Join(unused, "\n\t"))
+		switch check {
+		case UnusedRecordingsWarn:
+			t.Log(msg)
+		case UnusedRecordingsError:
+			t.Error(msg)
+		case UnusedRecordingsOff:
+		}
+	})
+}
+
+func findUnusedRecordings(store Store, used *usedRecordings) ([]string, error) {
+	recordings, err := store.List()
+	if err != nil {
+		return nil, fmt.Errorf("list recordings: %w", err)
+	}
+	var unused []string
+	for _, r := range recordings {
+		if !used.isUsed(r.Request) {
+			unused = append(unused, r.Request)
+		}
+	}
+	sort.Strings(unused)
+	return unused, nil
+}
@@ -0,0 +1,94 @@
+package hypert
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type cleanupMockT struct {
+	mockT
+	logged   string
+	cleanups []func()
+}
+
+func (m *cleanupMockT) Cleanup(f func()) {
+	m.cleanups = append(m.cleanups, f)
+}
+
+func (m *cleanupMockT) Log(args ...any) {
+	m.logged = args[0].(string)
+}
+
+func (m *cleanupMockT) Error(args ...any) {
+	m.failed = true
+	m.msg = args[0].(string)
+}
+
+func (m *cleanupMockT) runCleanups() {
+	for _, f := range m.cleanups {
+		f()
+	}
+}
+
+func TestUnusedRecordingsCheck(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"0.req.http", "0.resp.http", "1.req.http", "1.resp.http"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	store := NewFileStore(dir)
+
+	testCases := []struct {
+		name        string
+		check       UnusedRecordingsCheck
+		used        []string
+		expectError bool
+		expectLog   bool
+	}{
+		{
+			name:  "off",
+			check: UnusedRecordingsOff,
+		},
+		{
+			name:      "warn",
+			check:     UnusedRecordingsWarn,
+			used:      []string{"0.req.http"},
+			expectLog: true,
+		},
+		{
+			name:        "error",
+			check:       UnusedRecordingsError,
+			used:        []string{"0.req.http"},
+			expectError: true,
+		},
+		{
+			name:  "error, all recordings used",
+			check: UnusedRecordingsError,
+			used:  []string{"0.req.http", "1.req.http"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mT := &cleanupMockT{mockT: mockT{T: t}}
+			used := newUsedRecordings()
+			for _, name := range tc.used {
+				used.markUsed(filepath.Join(dir, name))
+			}
+			registerUnusedRecordingsCheck(mT, tc.check, store, used)
+			mT.runCleanups()
+
+			if mT.failed != tc.expectError {
+				t.Errorf("expected fail to be %v, got %v", tc.expectError, mT.failed)
+			}
+			if tc.expectError && !strings.Contains(mT.msg, filepath.Join(dir, "1.req.http")) {
+				t.Errorf("expected error to contain unused recording, got %q", mT.msg)
+			}
+			if tc.expectLog != strings.Contains(mT.logged, filepath.Join(dir, "1.req.http")) {
+				t.Errorf("expected log containing unused recording to be %v, got %q", tc.expectLog, mT.logged)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package hypert
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Missing is the value of Mismatch's Expected or Got field, if the compared field is not present in the request.
+const Missing = "<missing>"
+
+// Mismatch is a single difference between the recorded request and the one made in replay mode.
+type Mismatch struct {
+	// Field describes the part of the request, e.g. "path", "header 'Accept'" or "body $.user.name".
+	Field string
+	// Expected is the value from the recorded request, or Missing.
+	Expected string
+	// Got is the value from the request made in replay mode, or Missing.
+	Got string
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s: expected %s, got %s", m.Field, m.Expected, m.Got)
+}
+
+// ValidationError is returned by the validators, if the request doesn't match the recorded one.
+// It collects all the differences, so that they are reported at once.
+// ComposedRequestValidator merges the mismatches returned by the composed validators into a single ValidationError.
+//
+// In replay mode, the mismatches are reported as a diff, which is colored if the output is a terminal.
+// The colors can be forced on or off with HYPERT_COLOR environment variable, e.g. HYPERT_COLOR=1, and NO_COLOR disables them.
+type ValidationError struct {
+	Mismatches []Mismatch
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Mismatches))
+	for _, m := range e.Mismatches {
+		msgs = append(msgs, m.String())
+	}
+	return "request doesn't match the recorded one: " + strings.Join(msgs, "; ")
+}
+
+// validationError returns ValidationError with given mismatches, or nil if there are none.
+func validationError(mismatches []Mismatch) error {
+	if len(mismatches) == 0 {
+		return nil
+	}
+	return &ValidationError{Mismatches: mismatches}
+}
+
+// quoted formats the value in the same way as the validators' messages used to: 'value'.
+func quoted(v string) string {
+	return "'" + v + "'"
+}
+
+const (
+	colorReset = "\x1b[0m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// colorEnvVar forces the colors of the diff on or off, e.g. HYPERT_COLOR=1 in CI that renders ANSI colors.
+const colorEnvVar = "HYPERT_COLOR"
+
+// useColors is true, if stdout or stderr is a terminal, so that the logs saved to files don't contain escape codes.
+// HYPERT_COLOR environment variable set to true or false takes precedence, and NO_COLOR disables the colors, see https://no-color.org/
+func useColors() bool {
+	if _, noColor := os.LookupEnv("NO_COLOR"); noColor {
+		return false
+	}
+	if v, ok := os.LookupEnv(colorEnvVar); ok {
+		enabled, err := strconv.ParseBool(v)
+		return err == nil && enabled
+	}
+	return isTerminal(os.Stdout) || isTerminal(os.Stderr)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// formatDiff formats the mismatches of the request as a single diff, with "-" lines for recorded values and "+" lines for the actual ones.
+func formatDiff(request RequestData, recordingFile string, mismatches []Mismatch, colored bool) string {
+	paint := func(color, s string) string {
+		if !colored {
+			return s
+		}
+		return color + s + colorReset
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "request %s doesn't match the recording %s\n", request, recordingFile)
+	b.WriteString(paint(colorRed, "--- recorded") + "\n")
+	b.WriteString(paint(colorGreen, "+++ got") + "\n")
+	for _, m := range mismatches {
+		b.WriteString(paint(colorCyan, "@@ "+m.Field+" @@") + "\n")
+		b.WriteString(paint(colorRed, "- "+m.Expected) + "\n")
+		b.WriteString(paint(colorGreen, "+ "+m.Got) + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// asValidationError returns the mismatches, if err is ValidationError.
+func asValidationError(err error) ([]Mismatch, bool) {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Mismatches, true
+	}
+	return nil, false
+}
+
+// sortMismatches sorts the mismatches by field, so that the ones found while iterating over maps are reported in stable order.
+func sortMismatches(mismatches []Mismatch) {
+	sort.SliceStable(mismatches, func(i, j int) bool { return mismatches[i].Field < mismatches[j].Field })
+}
@@ -0,0 +1,31 @@
+package hypert
+
+import (
+	"os"
+	"testing"
+)
+
+func TestUseColors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		env      map[string]string
+		expected bool
+	}{
+		{name: "forced on", env: map[string]string{colorEnvVar: "1"}, expected: true},
+		{name: "forced off", env: map[string]string{colorEnvVar: "false"}, expected: false},
+		{name: "invalid value", env: map[string]string{colorEnvVar: "maybe"}, expected: false},
+		{name: "NO_COLOR takes precedence", env: map[string]string{colorEnvVar: "true", "NO_COLOR": ""}, expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", "") // restored after the test
+			os.Unsetenv("NO_COLOR")
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			if got := useColors(); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}